@@ -0,0 +1,282 @@
+package algorithm
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/times"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	// 每周一 05:00
+	cron, err := algorithm.ParseCron("0 5 * * MON", nil)
+	next := cron.Next(times.Now())
+
+支持 5 段(分 时 日 月 周)与 6 段(秒 分 时 日 月 周)表达式. 每段可以是 *、?、数字、名称(JAN-DEC, SUN-SAT)、
+范围 a-b、带步长的 a/n 或 a-b/n(* 同样可以带步长), 以及逗号分隔的列表; 周日可以写作 0 或 7.
+日与周都不是 * 时, 满足任意一个即可, 与标准 cron 一致. 也支持 @yearly、@monthly、@weekly、@daily、@hourly.
+*/
+
+// Schedule 重复任务的时间表
+type Schedule interface {
+	// Next 严格晚于 t 的下一次执行时间, 没有下一次时返回零值
+	Next(t time.Time) time.Time
+}
+
+// Cron 解析后的 cron 表达式
+type Cron struct {
+	second, minute, hour, dom, month, dow uint64
+	loc                                   *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = cronField{min: 0, max: 59}
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// starBit 标记该段写的是 * 或 ?, 用于日与周的匹配规则
+const starBit = 1 << 63
+
+// ParseCron 解析 cron 表达式, loc 为 nil 时使用 times 的时区
+func ParseCron(expr string, loc *time.Location) (*Cron, error) {
+	if loc == nil {
+		loc = times.Location()
+	}
+	spec := strings.TrimSpace(expr)
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron %q: expected 5 or 6 fields, got %d", expr, len(fields))
+	}
+
+	c := &Cron{loc: loc}
+	for i, f := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&c.second, secondField},
+		{&c.minute, minuteField},
+		{&c.hour, hourField},
+		{&c.dom, domField},
+		{&c.month, monthField},
+		{&c.dow, dowField},
+	} {
+		bits, err := f.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron %q: %w", expr, err)
+		}
+		*f.bits = bits
+	}
+	// 周日 7 与 0 相同
+	if c.dow&(1<<7) != 0 {
+		c.dow = c.dow&^(1<<7) | 1
+	}
+	return c, nil
+}
+
+// MustCron 解析失败时 panic, 用于常量表达式
+func MustCron(expr string, loc *time.Location) *Cron {
+	c, err := ParseCron(expr, loc)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		b, err := f.parseRange(part)
+		if err != nil {
+			return 0, err
+		}
+		bits |= b
+	}
+	return bits, nil
+}
+
+func (f cronField) parseRange(s string) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(s, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepPart)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid step %q", s)
+		}
+		step = n
+	}
+
+	var start, end int
+	var extra uint64
+	switch rangePart {
+	case "*", "?":
+		start, end = f.min, f.max
+		if !hasStep {
+			extra = starBit
+		}
+	default:
+		lo, hi, isRange := strings.Cut(rangePart, "-")
+		var err error
+		if start, err = f.value(lo); err != nil {
+			return 0, err
+		}
+		end = start
+		if isRange {
+			if end, err = f.value(hi); err != nil {
+				return 0, err
+			}
+		} else if hasStep {
+			end = f.max
+		}
+	}
+	if start > end {
+		return 0, fmt.Errorf("invalid range %q", s)
+	}
+
+	bits := extra
+	for v := start; v <= end; v += step {
+		bits |= 1 << uint(v)
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next 严格晚于 t 的下一次执行时间, 按 Cron 的时区计算, 返回值与 t 的时区相同. 5 年内没有匹配时返回零值
+func (c *Cron) Next(t time.Time) time.Time {
+	origin := t.Location()
+	t = t.In(c.loc)
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	added := false
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&c.month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, c.loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !c.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// 夏令时切换的当天零点可能不存在
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Hour())&c.hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, c.loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Minute())&c.minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for 1<<uint(t.Second())&c.second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t.In(origin)
+}
+
+// dayMatches 日与周都有限定时满足任意一个即可
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&c.dom != 0
+	dowMatch := 1<<uint(t.Weekday())&c.dow != 0
+	if c.dom&starBit != 0 || c.dow&starBit != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// every 固定间隔的时间表
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
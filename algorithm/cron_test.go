@@ -0,0 +1,82 @@
+package algorithm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	// 2026-10-19 是周一
+	from := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"0 0 * * *", from, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC)},
+		// 日与周都有限定时满足任意一个即可
+		{"0 0 13 * MON", from, time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+		{"0 0 20 * MON", from, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+		// 其中一个为 * 时两者都要满足
+		{"0 0 13 * *", from, time.Date(2026, 11, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * MON", from, time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)},
+		{"0 0 ? * 7", from, time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		// 2 月 29 日只在闰年出现
+		{"0 0 29 2 *", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2032, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", from, time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC), time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * JAN-MAR MON-FRI", from, time.Date(2027, 1, 1, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1-10/3 * *", from, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1-10/3 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 4, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * * *", from.Add(7500 * time.Millisecond), from.Add(15 * time.Second)},
+		{"@hourly", from, from.Add(time.Hour)},
+		{"@weekly", from, time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", from, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", from, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 5 年内没有匹配
+		{"0 0 30 2 *", from, time.Time{}},
+	}
+	for _, c := range cases {
+		cron, err := ParseCron(c.expr, time.UTC)
+		if err != nil {
+			t.Fatalf("ParseCron(%q): %v", c.expr, err)
+		}
+		if got := cron.Next(c.from); !got.Equal(c.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", c.expr, c.from, got, c.want)
+		}
+	}
+}
+
+func TestCronLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	cron := MustCron("0 5 * * *", loc)
+	// UTC 00:00 是 UTC+8 的 08:00, 下一次是次日 05:00
+	got := cron.Next(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	want := time.Date(2026, 10, 19, 21, 0, 0, 0, time.UTC)
+	if !got.Equal(want) || got.Location() != time.UTC {
+		t.Fatalf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * * FOO",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(expr, time.UTC); err == nil {
+			t.Errorf("ParseCron(%q) succeeded", expr)
+		}
+	}
+}
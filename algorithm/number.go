@@ -2,8 +2,8 @@ package algorithm
 
 import (
 	"errors"
+	"github.com/youngpto/funs_tool/times"
 	"sync"
-	"time"
 )
 
 /*
@@ -35,10 +35,11 @@ type Snowflake struct {
 	timestamp int64
 	workerid  int64
 	sequence  int64
+	clock     times.Clock
 }
 
 // NewNode returns a new snowflake worker that can be used to generate snowflake IDs
-func NewSnowflake(workerid int64) (*Snowflake, error) {
+func NewSnowflake(workerid int64, opts ...Option) (*Snowflake, error) {
 
 	if workerid < 0 || workerid > workeridMax {
 		return nil, errors.New("workerid must be between 0 and 1023")
@@ -48,6 +49,7 @@ func NewSnowflake(workerid int64) (*Snowflake, error) {
 		timestamp: 0,
 		workerid:  workerid,
 		sequence:  0,
+		clock:     newOptions(opts).clock,
 	}, nil
 }
 
@@ -56,14 +58,14 @@ func (s *Snowflake) Generate() int64 {
 
 	s.Lock()
 
-	now := time.Now().UnixNano() / 1000000
+	now := s.clock.Now().UnixNano() / 1000000
 
 	if s.timestamp == now {
 		s.sequence = (s.sequence + 1) & sequenceMask
 
 		if s.sequence == 0 {
 			for now <= s.timestamp {
-				now = time.Now().UnixNano() / 1000000
+				now = s.clock.Now().UnixNano() / 1000000
 			}
 		}
 	} else {
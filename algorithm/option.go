@@ -0,0 +1,28 @@
+package algorithm
+
+import "github.com/youngpto/funs_tool/times"
+
+// Option TimeWheel 与 Snowflake 的选项
+type Option func(opts *options)
+
+type options struct {
+	clock times.Clock
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		clock: times.GetClock(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithClock 使用的时钟, 默认为创建时 times.GetClock() 的时钟.
+// Snowflake 使用 FakeClock 时, 同一毫秒内超过 4096 个 ID 会等待时钟推进
+func WithClock(clock times.Clock) Option {
+	return func(opts *options) {
+		opts.clock = clock
+	}
+}
@@ -3,7 +3,7 @@ package algorithm
 import (
 	"container/list"
 	"github.com/youngpto/funs_tool/async"
-	"math"
+	"github.com/youngpto/funs_tool/times"
 	"sync"
 	"time"
 )
@@ -12,34 +12,90 @@ var timeWheel *TimeWheel
 
 // @author qiang.ou<qingqianludao@gmail.com>
 
+/*
+分层时间轮: 最底层每 interval 走一格, 共 slotNum 格; 上层每格等于下一层转一圈, 依次为 60、60、24 格,
+默认配置(10ms×100)下即秒、分、时、天四层, 更长的延时按需增加 64 格的层.
+定时器按到期的格数放入能容纳它的最低一层, 上层的格到达时将其中的定时器降到下层, 每次走格只处理到期的那一格.
+
+	timer := algorithm.GetTimeWheel().AddTimer(30*time.Second, uid, kickIdle)
+	timer.Reset(30 * time.Second) // 玩家有操作, 重新计时
+	if timer.Stop() {
+		// 在执行前取消
+	}
+	<-timer.Done()
+
+Timer 的方法可以在任意协程中调用, 包括定时器自己的回调中: 回调中 Reset 会重新计时, 回调结束后不再执行.
+
+	tw.AddInterval(time.Hour, sendHourlyReward)
+	reset, err := tw.AddCron("0 5 * * *", nil, dailyReset, algorithm.WithCatchUp())
+
+重复任务的回调参数为本次计划执行的时间(time.Time). 上一次回调结束后才计算下一次, 同一个任务不会重叠执行.
+回调耗时过长或进程卡顿而错过的执行默认跳过, WithCatchUp 时依次补上. Stop 会取消之后所有的执行.
+*/
+
 // Job 延时任务回调函数
 type Job func(arge interface{})
 
 // TaskData 回调函数参数类型
 type TaskData interface{}
 
+var upperSlots = []int{60, 60, 24}
+
+const overflowSlots = 64
+
 // TimeWheel 时间轮
 type TimeWheel struct {
 	sync.Mutex
-	interval          time.Duration       // 指针每隔多久往前移动一格
-	accumulator       int64               // 累加器
-	ticker            *time.Ticker        // 滴答计时器
-	slots             []*list.List        // 时间轮槽
-	timer             map[interface{}]int // key: 定时器唯一标识 value: 定时器所在的槽, 主要用于删除定时器
-	currentPos        int                 // 当前指针指向哪一个槽
-	slotNum           int                 // 槽数量
-	addTaskChannel    chan Task           // 新增任务channel
-	removeTaskChannel chan interface{}    // 删除任务channel
-	stopChannel       chan bool           // 停止定时器channel
+	interval    time.Duration         // 指针每隔多久往前移动一格
+	clock       times.Clock           // 时钟
+	ticker      times.Ticker          // 滴答计时器
+	levels      []*wheelLevel         // 各层时间轮, 0 为最底层
+	timer       map[interface{}]*Task // key: AddTimerCustom 的唯一标识 value: 尚未执行的定时器, 用于删除定时器
+	current     int64                 // 最底层已经走过的格数
+	slotNum     int                   // 最底层的槽数量
+	stopChannel chan bool             // 停止定时器channel
+}
+
+// wheelLevel 一层时间轮
+type wheelLevel struct {
+	unit  int64        // 每格相当于最底层的格数
+	slots []*list.List // 时间轮槽
 }
 
+// span 这一层转一圈相当于最底层的格数
+func (l *wheelLevel) span() int64 {
+	return l.unit * int64(len(l.slots))
+}
+
+const (
+	taskPending = iota // 等待执行
+	taskRunning        // 回调执行中
+	taskDone           // 已执行完或已停止
+)
+
 // Task 延时任务
 type Task struct {
-	delay  time.Duration // 延迟时间
-	circle int           // 时间轮需要转动几圈
-	key    interface{}   // 定时器唯一标识, 用于删除定时器
-	job    Job           // 定时器回调函数
-	data   TaskData      // 回调函数参数
+	delay    time.Duration // 延迟时间
+	expire   int64         // 到期时最底层走过的格数
+	deadline time.Time     // 到期时间
+	slot     *list.List    // 所在的槽
+	elem     *list.Element // 在槽中的节点
+	key      interface{}   // 定时器唯一标识, 用于删除定时器
+	job      Job           // 定时器回调函数
+	data     TaskData      // 回调函数参数
+	state    int
+	done     chan struct{}
+
+	repeat  Schedule  // 重复任务的时间表
+	catchUp bool      // 是否补上错过的执行
+	planned time.Time // 本次计划执行的时间
+	stopped bool      // 重复任务在执行中被停止
+}
+
+// Timer 定时器句柄
+type Timer struct {
+	tw   *TimeWheel
+	task *Task
 }
 
 func SetTimeWheel(t *TimeWheel) {
@@ -50,38 +106,44 @@ func GetTimeWheel() *TimeWheel {
 	return timeWheel
 }
 
-// NewTimeWheel 创建时间轮
-func NewTimeWheel(interval time.Duration, slotNum int) *TimeWheel {
+// NewTimeWheel 创建时间轮, interval 与 slotNum 为最底层的精度与槽数
+func NewTimeWheel(interval time.Duration, slotNum int, opts ...Option) *TimeWheel {
 	if interval <= 0 || slotNum <= 0 {
 		return nil
 	}
 	tw := &TimeWheel{
-		interval:          interval,
-		accumulator:       0,
-		slots:             make([]*list.List, slotNum),
-		timer:             make(map[interface{}]int),
-		currentPos:        0,
-		slotNum:           slotNum,
-		addTaskChannel:    make(chan Task),
-		removeTaskChannel: make(chan interface{}),
-		stopChannel:       make(chan bool),
+		interval:    interval,
+		clock:       newOptions(opts).clock,
+		timer:       make(map[interface{}]*Task),
+		slotNum:     slotNum,
+		stopChannel: make(chan bool),
 	}
 
-	tw.initSlots()
+	tw.initLevels()
 
 	return tw
 }
 
-// 初始化槽，每个槽指向一个双向链表
-func (tw *TimeWheel) initSlots() {
-	for i := 0; i < tw.slotNum; i++ {
-		tw.slots[i] = list.New()
+// 初始化各层, 每个槽指向一个双向链表
+func (tw *TimeWheel) initLevels() {
+	tw.addLevel(1, tw.slotNum)
+	for _, n := range upperSlots {
+		tw.addLevel(tw.levels[len(tw.levels)-1].span(), n)
 	}
 }
 
+func (tw *TimeWheel) addLevel(unit int64, slotNum int) {
+	level := &wheelLevel{unit: unit, slots: make([]*list.List, slotNum)}
+	for i := range level.slots {
+		level.slots[i] = list.New()
+	}
+	tw.levels = append(tw.levels, level)
+}
+
 // Start 启动时间轮
 func (tw *TimeWheel) Start(closeSig chan bool) {
-	tw.ticker = time.NewTicker(tw.interval)
+	tw.ticker = tw.clock.NewTicker(tw.interval)
+	begin := tw.clock.Now().Add(-time.Duration(tw.current) * tw.interval)
 	for {
 		select {
 		case <-tw.stopChannel:
@@ -90,13 +152,11 @@ func (tw *TimeWheel) Start(closeSig chan bool) {
 		case <-closeSig:
 			tw.ticker.Stop()
 			return
-		case <-tw.ticker.C:
-			tw.tickHandler()
-		case task := <-tw.addTaskChannel:
-			tw.addTask(&task)
-		case key := <-tw.removeTaskChannel:
-			tw.removeTask(key)
-
+		case now := <-tw.ticker.C():
+			// 滴答计时器在繁忙时会丢弃滴答, 按实际经过的时间补走
+			for target := int64(now.Sub(begin) / tw.interval); tw.current < target; {
+				tw.tickHandler()
+			}
 		}
 	}
 }
@@ -106,20 +166,59 @@ func (tw *TimeWheel) Stop() {
 	tw.stopChannel <- true
 }
 
-// AddTimer 添加定时器 不可撤销
-func (tw *TimeWheel) AddTimer(delay time.Duration, data TaskData, job Job) {
-	if delay <= 0 {
-		return
+// AddTimer 添加定时器, 通过返回的句柄撤销或重新计时. delay <= 0 时不会执行, 返回已停止的定时器
+func (tw *TimeWheel) AddTimer(delay time.Duration, data TaskData, job Job) *Timer {
+	return tw.addTask(&Task{delay: delay, data: data, job: job})
+}
+
+// AddTimerCustom 可以通过key来撤销一个未执行的定时器, key 相同的未执行定时器会被停止并替换
+func (tw *TimeWheel) AddTimerCustom(delay time.Duration, key interface{}, data TaskData, job Job) *Timer {
+	return tw.addTask(&Task{delay: delay, key: key, data: data, job: job})
+}
+
+// RepeatOption 重复任务的选项
+type RepeatOption func(task *Task)
+
+// WithCatchUp 错过的执行依次补上, 默认跳过到下一个未来的时间
+func WithCatchUp() RepeatOption {
+	return func(task *Task) {
+		task.catchUp = true
 	}
-	tw.addTaskChannel <- Task{delay: delay, data: data, job: job}
 }
 
-// AddTimerCustom 可以通过key来撤销一个未执行的定时器
-func (tw *TimeWheel) AddTimerCustom(delay time.Duration, key interface{}, data TaskData, job Job) {
-	if delay <= 0 {
-		return
+// AddInterval 每隔 interval 执行一次, 首次在 interval 之后. interval <= 0 时返回已停止的定时器
+func (tw *TimeWheel) AddInterval(interval time.Duration, job Job, opts ...RepeatOption) *Timer {
+	if interval <= 0 {
+		return tw.addTask(&Task{job: job})
+	}
+	return tw.AddSchedule(every(interval), job, opts...)
+}
+
+// AddCron 按 cron 表达式执行, loc 为 nil 时使用 times 的时区
+func (tw *TimeWheel) AddCron(expr string, loc *time.Location, job Job, opts ...RepeatOption) (*Timer, error) {
+	cron, err := ParseCron(expr, loc)
+	if err != nil {
+		return nil, err
 	}
-	tw.addTaskChannel <- Task{delay: delay, key: key, data: data, job: job}
+	return tw.AddSchedule(cron, job, opts...), nil
+}
+
+// AddSchedule 按时间表重复执行, 时间表没有下一次时结束
+func (tw *TimeWheel) AddSchedule(schedule Schedule, job Job, opts ...RepeatOption) *Timer {
+	task := &Task{job: job, repeat: schedule}
+	for _, opt := range opts {
+		opt(task)
+	}
+	now := tw.clock.Now()
+	task.planned = schedule.Next(now)
+	task.data = task.planned
+	if !task.planned.IsZero() {
+		task.delay = task.planned.Sub(now)
+		if task.delay <= 0 {
+			task.delay = tw.interval
+		}
+	}
+	return tw.addTask(task)
 }
 
 // RemoveTimer 删除定时器 key为添加定时器时传递的定时器唯一标识
@@ -127,90 +226,206 @@ func (tw *TimeWheel) RemoveTimer(key interface{}) {
 	if key == nil {
 		return
 	}
-	tw.removeTaskChannel <- key
+	tw.Lock()
+	defer tw.Unlock()
+	if task, ok := tw.timer[key]; ok {
+		tw.stopTask(task)
+	}
 }
 
-func (tw *TimeWheel) tickHandler() {
-	l := tw.slots[tw.currentPos]
-	if tw.currentPos == tw.slotNum-1 {
-		tw.currentPos = 0
-	} else {
-		tw.currentPos++
+// Stop 在执行前取消定时器, 返回是否成功取消. 已执行、执行中或已停止时返回 false; 重复任务执行中时取消之后的执行并返回 true
+func (t *Timer) Stop() bool {
+	t.tw.Lock()
+	defer t.tw.Unlock()
+	switch {
+	case t.task.state == taskPending:
+		t.tw.stopTask(t.task)
+		return true
+	case t.task.state == taskRunning && t.task.repeat != nil && !t.task.stopped:
+		t.task.stopped = true
+		return true
 	}
-	tw.scanAndRunTask(l)
+	return false
 }
 
-// 扫描链表中过期定时器, 并执行回调函数
-func (tw *TimeWheel) scanAndRunTask(l *list.List) {
+// Reset 从现在起 d 之后执行. 执行中(包括在自己的回调中)调用会再执行一次, 已执行完或已停止时返回 false
+func (t *Timer) Reset(d time.Duration) bool {
+	t.tw.Lock()
+	defer t.tw.Unlock()
+	task := t.task
+	switch task.state {
+	case taskPending:
+		task.slot.Remove(task.elem)
+	case taskRunning:
+		task.state = taskPending
+		task.stopped = false
+		if task.key != nil {
+			if old, ok := t.tw.timer[task.key]; ok {
+				t.tw.stopTask(old)
+			}
+			t.tw.timer[task.key] = task
+		}
+	default:
+		return false
+	}
+	task.delay = d
+	if task.repeat != nil {
+		task.planned = t.tw.clock.Now().Add(d)
+		task.data = task.planned
+	}
+	t.tw.schedule(task)
+	return true
+}
+
+// Remaining 距离执行的剩余时间, 不在等待执行时返回 0
+func (t *Timer) Remaining() time.Duration {
+	t.tw.Lock()
+	defer t.tw.Unlock()
+	if t.task.state != taskPending {
+		return 0
+	}
+	if remain := t.task.deadline.Sub(t.tw.clock.Now()); remain > 0 {
+		return remain
+	}
+	return 0
+}
+
+// Done 定时器的回调执行完或被停止后关闭
+func (t *Timer) Done() <-chan struct{} {
+	return t.task.done
+}
+
+// 走一格: 先将上层到达的格降级, 再执行最底层当前格中的定时器
+func (tw *TimeWheel) tickHandler() {
 	tw.Lock()
-	defer tw.Unlock()
-	for e := l.Front(); e != nil; {
-		task := e.Value.(*Task)
-		if task.circle > 0 {
-			task.circle--
-			e = e.Next()
+	tw.current++
+	for i := len(tw.levels) - 1; i > 0; i-- {
+		level := tw.levels[i]
+		if tw.current%level.unit != 0 {
 			continue
 		}
+		l := level.slots[tw.current/level.unit%int64(len(level.slots))]
+		for e := l.Front(); e != nil; e = l.Front() {
+			task := l.Remove(e).(*Task)
+			tw.place(task)
+		}
+	}
 
-		next := e.Next()
-		l.Remove(e)
-		delete(tw.timer, task.key)
-		e = next
+	l := tw.levels[0].slots[tw.current%int64(tw.slotNum)]
+	var expired []*Task
+	var data []interface{}
+	for e := l.Front(); e != nil; e = l.Front() {
+		task := l.Remove(e).(*Task)
+		tw.unbind(task)
+		task.state = taskRunning
+		expired = append(expired, task)
+		// 重复任务的 data 会在回调执行期间被 Reset 或下一次计时改写, 在锁内取出本次的值
+		data = append(data, task.data)
+	}
+	tw.Unlock()
 
+	for i, task := range expired {
+		task, data := task, data[i]
 		async.Go(func() {
-			task.job(task.data)
+			defer tw.finish(task)
+			task.job(data)
 		})
 	}
 }
 
+// 回调结束, 重复任务计算下一次执行时间, 其他任务在回调中没有重新计时则关闭 done
+func (tw *TimeWheel) finish(task *Task) {
+	tw.Lock()
+	defer tw.Unlock()
+	if task.state != taskRunning {
+		return
+	}
+	if task.repeat != nil && !task.stopped {
+		now := tw.clock.Now()
+		next := task.repeat.Next(task.planned)
+		if !task.catchUp && !next.IsZero() && !next.After(now) {
+			next = task.repeat.Next(now)
+		}
+		if !next.IsZero() {
+			task.state = taskPending
+			task.planned = next
+			task.data = next
+			task.delay = next.Sub(now)
+			tw.schedule(task)
+			return
+		}
+	}
+	task.state = taskDone
+	close(task.done)
+}
+
 // 新增任务到链表中
-func (tw *TimeWheel) addTask(task *Task) {
+func (tw *TimeWheel) addTask(task *Task) *Timer {
+	task.done = make(chan struct{})
+	timer := &Timer{tw: tw, task: task}
+	if task.delay <= 0 {
+		task.state = taskDone
+		close(task.done)
+		return timer
+	}
+
 	tw.Lock()
 	defer tw.Unlock()
-	if task.key == nil {
-		if tw.accumulator >= math.MaxInt64 {
-			tw.accumulator = 0
+	if task.key != nil {
+		if old, ok := tw.timer[task.key]; ok {
+			tw.stopTask(old)
 		}
-		tw.accumulator++
-		task.key = tw.accumulator
+		tw.timer[task.key] = task
 	}
-	pos, circle := tw.getPositionAndCircle(task.delay)
-	task.circle = circle
+	tw.schedule(task)
+	return timer
+}
 
-	tw.slots[pos].PushBack(task)
+// 按 delay 计算到期的格数并放入时间轮, 需持有锁
+func (tw *TimeWheel) schedule(task *Task) {
+	task.deadline = tw.clock.Now().Add(task.delay)
+	task.expire = tw.current + tw.ticks(task.delay)
+	tw.place(task)
+}
 
-	tw.timer[task.key] = pos
+// 从时间轮中移除并关闭 done, 需持有锁
+func (tw *TimeWheel) stopTask(task *Task) {
+	task.slot.Remove(task.elem)
+	tw.unbind(task)
+	task.state = taskDone
+	close(task.done)
 }
 
-// 获取定时器在槽中的位置, 时间轮需要转动的圈数
-func (tw *TimeWheel) getPositionAndCircle(d time.Duration) (int, int) {
-	delayMillisecond := int(d.Nanoseconds() / 1e6)
-	intervalMillisecond := int(tw.interval.Nanoseconds() / 1e6)
-	circle := int(delayMillisecond / intervalMillisecond / tw.slotNum)
-	pos := int(tw.currentPos+delayMillisecond/intervalMillisecond) % tw.slotNum
-	return pos, circle
+// 解除 key 与任务的绑定, key 已被新的定时器使用时保留
+func (tw *TimeWheel) unbind(task *Task) {
+	if task.key != nil && tw.timer[task.key] == task {
+		delete(tw.timer, task.key)
+	}
 }
 
-// 从链表中删除任务
-func (tw *TimeWheel) removeTask(key interface{}) {
-	tw.Lock()
-	defer tw.Unlock()
-	// 获取定时器所在的槽
-	position, ok := tw.timer[key]
-	if !ok {
-		return
+// 延迟时间对应的格数, 向上取整且至少为 1
+func (tw *TimeWheel) ticks(d time.Duration) int64 {
+	ticks := int64((d + tw.interval - 1) / tw.interval)
+	if ticks < 1 {
+		ticks = 1
 	}
-	// 获取槽指向的链表
-	l := tw.slots[position]
-	for e := l.Front(); e != nil; {
-		task := e.Value.(*Task)
-		if task.key == key {
-			delete(tw.timer, task.key)
-			l.Remove(e)
-		}
+	return ticks
+}
 
-		e = e.Next()
+// 放入能容纳剩余格数的最低一层. 降级时剩余 0 格的任务放入最底层的当前格, 随后在同一次走格中执行
+func (tw *TimeWheel) place(task *Task) {
+	remain := task.expire - tw.current
+	for remain >= tw.levels[len(tw.levels)-1].span() {
+		tw.addLevel(tw.levels[len(tw.levels)-1].span(), overflowSlots)
+	}
+	var level *wheelLevel
+	for _, level = range tw.levels {
+		if remain < level.span() {
+			break
+		}
 	}
+	task.slot = level.slots[task.expire/level.unit%int64(len(level.slots))]
+	task.elem = task.slot.PushBack(task)
 }
 
 var stopTimeWheel = make(chan bool, 1)
@@ -0,0 +1,293 @@
+package algorithm
+
+import (
+	"github.com/youngpto/funs_tool/times"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// wheelDriver 用 FakeClock 手动走格, 与 Start 收到滴答后的处理相同
+type wheelDriver struct {
+	t     *testing.T
+	tw    *TimeWheel
+	clock *times.FakeClock
+}
+
+func newDriver(t *testing.T, interval time.Duration, slotNum int) *wheelDriver {
+	clock := times.NewFakeClock(testStart)
+	return &wheelDriver{t: t, tw: NewTimeWheel(interval, slotNum, WithClock(clock)), clock: clock}
+}
+
+func (d *wheelDriver) step(n int64) {
+	for i := int64(0); i < n; i++ {
+		d.clock.Advance(d.tw.interval)
+		d.tw.tickHandler()
+	}
+}
+
+func (d *wheelDriver) state(timer *Timer) int {
+	d.tw.Lock()
+	defer d.tw.Unlock()
+	return timer.task.state
+}
+
+// waitState 回调在其他协程中执行, 等待任务进入 want 状态
+func (d *wheelDriver) waitState(timer *Timer, want int) {
+	d.t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for d.state(timer) != want {
+		if time.Now().After(deadline) {
+			d.t.Fatalf("task state = %d, want %d", d.state(timer), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// level 任务所在的层
+func (d *wheelDriver) level(timer *Timer) int {
+	d.tw.Lock()
+	defer d.tw.Unlock()
+	for i, level := range d.tw.levels {
+		for _, slot := range level.slots {
+			if slot == timer.task.slot {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func receive(t *testing.T, c <-chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("job did not run")
+	}
+	return time.Time{}
+}
+
+func TestTimeWheelCascade(t *testing.T) {
+	d := newDriver(t, 10*time.Millisecond, 100)
+	// 从不对齐的位置开始, 覆盖上层格的边界
+	d.step(37)
+	cases := []struct {
+		delay time.Duration
+		level int
+	}{
+		{50 * time.Millisecond, 0},
+		{990 * time.Millisecond, 0},
+		{2 * time.Second, 1},
+		{59*time.Second + 990*time.Millisecond, 1},
+		{90 * time.Second, 2},
+		{time.Hour + time.Minute + 1230*time.Millisecond, 3},
+	}
+	for _, c := range cases {
+		timer := d.tw.AddTimer(c.delay, nil, func(interface{}) {})
+		if level := d.level(timer); level != c.level {
+			t.Fatalf("%v: placed in level %d, want %d", c.delay, level, c.level)
+		}
+		ticks := int64(c.delay / d.tw.interval)
+		d.step(ticks - 1)
+		if d.state(timer) != taskPending {
+			t.Fatalf("%v: ran early", c.delay)
+		}
+		if level := d.level(timer); level != 0 {
+			t.Fatalf("%v: still in level %d one tick before expiry", c.delay, level)
+		}
+		if remain := timer.Remaining(); remain != d.tw.interval {
+			t.Fatalf("%v: Remaining = %v, want %v", c.delay, remain, d.tw.interval)
+		}
+		d.step(1)
+		if d.state(timer) == taskPending {
+			t.Fatalf("%v: did not run on time", c.delay)
+		}
+		d.waitState(timer, taskDone)
+	}
+}
+
+func TestTimeWheelOverflow(t *testing.T) {
+	// 各层的跨度为 2s、2m、2h、2d, 更长的延时需要增加层
+	d := newDriver(t, time.Second, 2)
+	timer := d.tw.AddTimer(3*24*time.Hour, nil, func(interface{}) {})
+	if len(d.tw.levels) != 5 || d.level(timer) != 4 {
+		t.Fatalf("levels = %d, task in level %d, want 5 and 4", len(d.tw.levels), d.level(timer))
+	}
+	far := d.tw.AddTimer(500*24*time.Hour, nil, func(interface{}) {})
+	if len(d.tw.levels) != 6 || d.level(far) != 5 {
+		t.Fatalf("levels = %d, far task in level %d, want 6 and 5", len(d.tw.levels), d.level(far))
+	}
+	if !far.Stop() {
+		t.Fatal("Stop on a pending timer returned false")
+	}
+
+	d.step(3*24*3600 - 1)
+	if d.state(timer) != taskPending {
+		t.Fatal("overflow task ran early")
+	}
+	d.step(1)
+	d.waitState(timer, taskDone)
+}
+
+func TestTimerStopReset(t *testing.T) {
+	d := newDriver(t, 10*time.Millisecond, 100)
+	ran := make(chan time.Time, 10)
+	job := func(interface{}) { ran <- d.clock.Now() }
+
+	stopped := d.tw.AddTimer(time.Second, nil, job)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("Stop should succeed once")
+	}
+	select {
+	case <-stopped.Done():
+	default:
+		t.Fatal("Done not closed after Stop")
+	}
+
+	timer := d.tw.AddTimer(time.Second, nil, job)
+	d.step(50)
+	if !timer.Reset(2 * time.Second) {
+		t.Fatal("Reset on a pending timer returned false")
+	}
+	if remain := timer.Remaining(); remain != 2*time.Second {
+		t.Fatalf("Remaining = %v, want 2s", remain)
+	}
+	d.step(199)
+	if d.state(timer) != taskPending {
+		t.Fatal("reset timer ran early")
+	}
+	d.step(1)
+	if at := receive(t, ran); !at.Equal(testStart.Add(2500 * time.Millisecond)) {
+		t.Fatalf("ran at %v", at)
+	}
+	d.waitState(timer, taskDone)
+	if timer.Reset(time.Second) || timer.Stop() || timer.Remaining() != 0 {
+		t.Fatal("Reset/Stop on a finished timer should fail")
+	}
+	if len(ran) != 0 {
+		t.Fatal("stopped timer ran")
+	}
+}
+
+func TestTimeWheelCustomKey(t *testing.T) {
+	d := newDriver(t, 10*time.Millisecond, 100)
+	first := d.tw.AddTimerCustom(time.Second, "kick", nil, func(interface{}) {})
+	second := d.tw.AddTimerCustom(time.Second, "kick", nil, func(interface{}) {})
+	if d.state(first) != taskDone {
+		t.Fatal("timer with the same key was not replaced")
+	}
+	d.tw.RemoveTimer("kick")
+	if d.state(second) != taskDone {
+		t.Fatal("RemoveTimer did not stop the timer")
+	}
+}
+
+func TestRepeatResetStopWhileRunning(t *testing.T) {
+	d := newDriver(t, 10*time.Millisecond, 100)
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	timer := d.tw.AddInterval(time.Second, func(data interface{}) {
+		started <- data.(time.Time)
+		<-release
+	})
+
+	d.step(100)
+	if at := receive(t, started); !at.Equal(testStart.Add(time.Second)) {
+		t.Fatalf("first run planned at %v", at)
+	}
+	// 执行中 Reset: 本次结束后从 Reset 时起 5s 再执行
+	resetAt := d.clock.Now()
+	if !timer.Reset(5 * time.Second) {
+		t.Fatal("Reset on a running repeat task returned false")
+	}
+	release <- struct{}{}
+	d.waitState(timer, taskPending)
+	d.step(499)
+	if len(started) != 0 {
+		t.Fatal("reset repeat task ran early")
+	}
+	d.step(1)
+	if at := receive(t, started); !at.Equal(resetAt.Add(5 * time.Second)) {
+		t.Fatalf("run after Reset planned at %v, want %v", at, resetAt.Add(5*time.Second))
+	}
+
+	// 执行中 Stop: 本次结束后不再执行
+	if !timer.Stop() {
+		t.Fatal("Stop on a running repeat task returned false")
+	}
+	release <- struct{}{}
+	select {
+	case <-timer.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done not closed after the stopped run finished")
+	}
+	d.step(300)
+	if len(started) != 0 {
+		t.Fatal("stopped repeat task ran again")
+	}
+}
+
+func TestRepeatSkipAndCatchUp(t *testing.T) {
+	for _, catchUp := range []bool{false, true} {
+		d := newDriver(t, 10*time.Millisecond, 100)
+		started := make(chan time.Time, 10)
+		release := make(chan struct{})
+		var opts []RepeatOption
+		if catchUp {
+			opts = append(opts, WithCatchUp())
+		}
+		timer := d.tw.AddInterval(time.Second, func(data interface{}) {
+			started <- data.(time.Time)
+			<-release
+		}, opts...)
+
+		d.step(100)
+		receive(t, started)
+		// 回调阻塞 3.5s, 错过了 2s、3s、4s 的执行
+		d.step(350)
+		release <- struct{}{}
+		d.waitState(timer, taskPending)
+
+		want := testStart.Add(5500 * time.Millisecond)
+		if catchUp {
+			want = testStart.Add(2 * time.Second)
+		}
+		for i := 0; i < 200 && d.state(timer) == taskPending; i++ {
+			d.step(1)
+		}
+		if at := receive(t, started); !at.Equal(want) {
+			t.Fatalf("catchUp=%v: next run planned at %v, want %v", catchUp, at, want)
+		}
+		timer.Stop()
+		release <- struct{}{}
+		<-timer.Done()
+	}
+}
+
+func TestTimeWheelStartWithFakeClock(t *testing.T) {
+	clock := times.NewFakeClock(testStart)
+	tw := NewTimeWheel(10*time.Millisecond, 100, WithClock(clock))
+	closeSig := make(chan bool)
+	go tw.Start(closeSig)
+	defer close(closeSig)
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	timer := tw.AddTimer(3*time.Second, nil, func(interface{}) {})
+	// 一次推进多格, 时间轮按经过的时间补走
+	for i := 0; ; i++ {
+		clock.Advance(3 * time.Second)
+		select {
+		case <-timer.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if i == 10 {
+			t.Fatal("timer did not run after advancing the fake clock")
+		}
+	}
+}
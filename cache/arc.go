@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"container/list"
+	"github.com/youngpto/funs_tool/math_utils"
+)
+
+// ARCCache 自适应替换缓存, 在最近使用(T1)与频繁使用(T2)之间按命中的幽灵记录(B1/B2)调整比例, 抗扫描.
+// 设置 WithCost 时各区域的大小按成本计算
+type ARCCache[K comparable, V any] struct {
+	baseCache[K, V]
+}
+
+func NewARCCache[K comparable, V any](capacity int, popCb PopCallback[K, V], opts ...Option) *ARCCache[K, V] {
+	arc := &ARCCache[K, V]{
+		baseCache: newBaseCache[K, V](capacity, newARCPolicy[K, V](capacity), popCb, opts),
+	}
+	arc.startSweeper()
+	return arc
+}
+
+const (
+	arcT1 = iota
+	arcT2
+)
+
+type arcPolicy[K comparable, V any] struct {
+	capacity int64
+	target   int64      // T1 的目标大小
+	t1, t2   *list.List // 常驻条目, 表头为最近使用
+	b1, b2   *list.List // 淘汰条目的幽灵记录
+	g1, g2   map[K]*list.Element
+	size     [2]int64 // T1、T2 的成本
+	ghost    [2]int64 // B1、B2 的成本
+	fromB2   bool     // 最近一次新增是否命中 B2
+}
+
+// arcGhost 淘汰条目的键与淘汰时的成本
+type arcGhost[K comparable] struct {
+	key  K
+	cost int64
+}
+
+func newARCPolicy[K comparable, V any](capacity int) *arcPolicy[K, V] {
+	p := &arcPolicy[K, V]{capacity: int64(capacity)}
+	p.reset()
+	return p
+}
+
+func (p *arcPolicy[K, V]) list(seg int) *list.List {
+	if seg == arcT1 {
+		return p.t1
+	}
+	return p.t2
+}
+
+func (p *arcPolicy[K, V]) push(e *entry[K, V], seg int) {
+	e.seg = seg
+	e.elem = p.list(seg).PushFront(e)
+	p.size[seg] += e.cost
+}
+
+func (p *arcPolicy[K, V]) add(e *entry[K, V]) {
+	p.fromB2 = false
+	// 不限容量时不会淘汰, 不调整 T1 的目标大小
+	bounded := p.capacity >= 0
+	if elem, ok := p.g1[e.key]; ok {
+		g := p.forget(p.b1, p.g1, arcT1, elem)
+		if bounded {
+			p.target = math_utils.Min(p.capacity, p.target+g.cost*math_utils.Max(1, p.ghost[arcT2]/math_utils.Max(1, p.ghost[arcT1]+g.cost)))
+		}
+		p.push(e, arcT2)
+	} else if elem, ok := p.g2[e.key]; ok {
+		g := p.forget(p.b2, p.g2, arcT2, elem)
+		if bounded {
+			p.target = math_utils.Max(0, p.target-g.cost*math_utils.Max(1, p.ghost[arcT1]/math_utils.Max(1, p.ghost[arcT2]+g.cost)))
+		}
+		p.push(e, arcT2)
+		p.fromB2 = true
+	} else {
+		p.push(e, arcT1)
+	}
+}
+
+// forget 移除一条幽灵记录
+func (p *arcPolicy[K, V]) forget(l *list.List, g map[K]*list.Element, seg int, elem *list.Element) arcGhost[K] {
+	ghost := l.Remove(elem).(arcGhost[K])
+	delete(g, ghost.key)
+	p.ghost[seg] -= ghost.cost
+	return ghost
+}
+
+func (p *arcPolicy[K, V]) hit(e *entry[K, V]) {
+	p.remove(e)
+	p.push(e, arcT2)
+}
+
+func (p *arcPolicy[K, V]) remove(e *entry[K, V]) {
+	p.list(e.seg).Remove(e.elem)
+	p.size[e.seg] -= e.cost
+}
+
+func (p *arcPolicy[K, V]) recost(e *entry[K, V], old int64) {
+	p.size[e.seg] += e.cost - old
+}
+
+func (p *arcPolicy[K, V]) evict() *entry[K, V] {
+	var victim *entry[K, V]
+	if t1 := p.size[arcT1]; p.t1.Len() > 0 && (t1 > p.target || (p.fromB2 && t1 == p.target) || p.t2.Len() == 0) {
+		victim = p.t1.Back().Value.(*entry[K, V])
+		p.remove(victim)
+		p.g1[victim.key] = p.b1.PushFront(arcGhost[K]{key: victim.key, cost: victim.cost})
+		p.ghost[arcT1] += victim.cost
+	} else if p.t2.Len() > 0 {
+		victim = p.t2.Back().Value.(*entry[K, V])
+		p.remove(victim)
+		p.g2[victim.key] = p.b2.PushFront(arcGhost[K]{key: victim.key, cost: victim.cost})
+		p.ghost[arcT2] += victim.cost
+	}
+	p.trimGhosts()
+	return victim
+}
+
+// trimGhosts 保持 |T1|+|B1| <= c 且 |B1|+|B2| <= c
+func (p *arcPolicy[K, V]) trimGhosts() {
+	if p.capacity < 0 {
+		return
+	}
+	for p.b1.Len() > 0 && p.size[arcT1]+p.ghost[arcT1] > p.capacity {
+		p.forget(p.b1, p.g1, arcT1, p.b1.Back())
+	}
+	for p.b1.Len()+p.b2.Len() > 0 && p.ghost[arcT1]+p.ghost[arcT2] > p.capacity {
+		if p.b2.Len() > 0 {
+			p.forget(p.b2, p.g2, arcT2, p.b2.Back())
+		} else {
+			p.forget(p.b1, p.g1, arcT1, p.b1.Back())
+		}
+	}
+}
+
+func (p *arcPolicy[K, V]) walk(f func(e *entry[K, V]) bool) {
+	for _, l := range []*list.List{p.t2, p.t1} {
+		for cursor := l.Front(); cursor != nil; cursor = cursor.Next() {
+			if !f(cursor.Value.(*entry[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+func (p *arcPolicy[K, V]) resize(capacity int) {
+	p.capacity = int64(capacity)
+	if capacity >= 0 {
+		p.target = math_utils.Min(p.target, p.capacity)
+	}
+	p.target = math_utils.Max(0, p.target)
+	p.trimGhosts()
+}
+
+func (p *arcPolicy[K, V]) reset() {
+	p.target = 0
+	p.t1, p.t2 = list.New(), list.New()
+	p.b1, p.b2 = list.New(), list.New()
+	p.g1, p.g2 = make(map[K]*list.Element), make(map[K]*list.Element)
+	p.size, p.ghost = [2]int64{}, [2]int64{}
+	p.fromB2 = false
+}
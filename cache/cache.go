@@ -2,174 +2,473 @@ package utils
 
 import (
 	"container/list"
+	"fmt"
 	"github.com/youngpto/funs_tool/sync_utils"
+	"time"
 )
 
-type PopCallback[K comparable, V any] func(key K, val V)
+/*
+	sessions := utils.NewLRUCache[int64, *Session](10000, nil,
+		utils.WithTTL(30*time.Minute),
+		utils.WithSweeper(time.Minute),
+	)
+	sessions.SetWithTTL(uid, session, time.Hour)
+	defer sessions.Close()
 
-type LRUCache[K comparable, V any] struct {
-	capacity int
-	stack    *list.List
-	items    map[K]*list.Element
-	lock     sync_utils.ReMutex
-	popCb    PopCallback[K, V]
+	players, err := utils.New[int64, *Player](utils.Policy(cfg.CachePolicy), 5000, onPop)
+
+	chunks := utils.NewLRUCache[int64, []byte](256<<20, nil,
+		utils.WithCost(func(id int64, data []byte) int64 { return int64(len(data)) }),
+	)
+	if !chunks.Set(id, data) {
+		// 单个条目超过了 256MB
+	}
+
+过期的条目在 Get/Peek/MultipleGet/Contains 时按未命中处理并被移除, 开启 WithSweeper 后由时间轮定期清理.
+各淘汰策略共用同一套回调、容量与过期语义, 只在超出容量时选择淘汰哪个条目上有区别.
+*/
+
+// Reason 条目离开缓存的原因
+type Reason int
+
+const (
+	ReasonCapacity Reason = iota // 超出容量被淘汰, 包括 ReCapacity 缩容
+	ReasonRemoved                // 调用 Remove
+	ReasonReplaced               // Set 覆盖已有的键, 回调收到的是旧值
+	ReasonExpired                // 过期
+	ReasonFlushed                // 调用 FlushAll
+)
+
+var reasonNames = []string{"capacity", "removed", "replaced", "expired", "flushed"}
+
+func (r Reason) String() string {
+	if r >= 0 && int(r) < len(reasonNames) {
+		return reasonNames[r]
+	}
+	return "unknown"
+}
+
+// PopCallback 条目被淘汰、移除、覆盖、过期或清空时的回调
+type PopCallback[K comparable, V any] func(key K, val V, reason Reason)
+
+// Cache 各淘汰策略的公共接口
+type Cache[K comparable, V any] interface {
+	Get(key K) (value V, ok bool)
+	MultipleGet(keys []K) (values []V, miss []K)
+	Peek(key K) (value V)
+	TTL(key K) (time.Duration, bool)
+	Range(f func(key K, value V) (shouldContinue bool))
+	Contains(key K) bool
+	Set(key K, value V) bool
+	SetWithTTL(key K, value V, ttl time.Duration) bool
+	Remove(key K) bool
+	RemoveExpired() int
+	ReCapacity(capacity int)
+	Size() int
+	Capacity() int
+	Cost() int64
+	FlushAll()
+	Close()
+	Stats() Stats
+	ResetStats()
+}
+
+// Policy 淘汰策略, 用于按配置选择实现
+type Policy string
+
+const (
+	PolicyLRU     Policy = "lru"
+	PolicyLFU     Policy = "lfu"
+	PolicyARC     Policy = "arc"
+	PolicyTinyLFU Policy = "tinylfu"
+
+	PolicyShardedLRU Policy = "sharded-lru"
+)
+
+// New 按淘汰策略创建缓存, capacity < 0 表示不限容量. 策略未知或 WithCost 的键值类型与缓存不一致时返回错误,
+// 直接调用各策略的构造函数时这两种情况会 panic
+func New[K comparable, V any](policy Policy, capacity int, popCb PopCallback[K, V], opts ...Option) (Cache[K, V], error) {
+	if err := checkOptions[K, V](opts); err != nil {
+		return nil, err
+	}
+	switch policy {
+	case PolicyLRU:
+		return NewLRUCache(capacity, popCb, opts...), nil
+	case PolicyLFU:
+		return NewLFUCache(capacity, popCb, opts...), nil
+	case PolicyARC:
+		return NewARCCache(capacity, popCb, opts...), nil
+	case PolicyTinyLFU:
+		return NewTinyLFUCache(capacity, popCb, opts...), nil
+	case PolicyShardedLRU:
+		return NewShardedLRUCache(capacity, popCb, opts...), nil
+	}
+	return nil, fmt.Errorf("cache: unknown policy %q", policy)
+}
+
+// peeker 查看条目是否存在, 不更新访问顺序与统计
+type peeker[K comparable, V any] interface {
+	peek(key K) (V, bool)
 }
 
 type entry[K comparable, V any] struct {
-	key   K
-	value V
+	key    K
+	value  V
+	expire int64         // 过期时间(纳秒时间戳), 0 表示不过期
+	elem   *list.Element // 在策略链表中的节点
+	cost   int64         // 成本, 未设置 WithCost 时为 1
+	freq   int           // 访问频次, 由策略维护
+	seg    int           // 所在的策略分段, 由策略维护
+}
+
+func (e *entry[K, V]) expired(now int64) bool {
+	return e.expire > 0 && now >= e.expire
+}
+
+// policy 淘汰策略, 只维护条目的顺序, 由 baseCache 加锁调用
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])               // 新条目
+	hit(e *entry[K, V])               // 条目被访问或覆盖
+	remove(e *entry[K, V])            // 条目被移除或过期
+	recost(e *entry[K, V], old int64) // 覆盖后条目的成本由 old 变为 e.cost
+	evict() *entry[K, V]              // 选出并移除一个淘汰的条目
+	walk(f func(e *entry[K, V]) bool) // 按保留优先级从高到低遍历
+	resize(capacity int)              // 容量变化
+	reset()                           // 清空
+}
+
+type baseCache[K comparable, V any] struct {
+	capacity int
+	used     int64 // 条目成本之和
+	cost     func(key K, value V) int64
+	items    map[K]*entry[K, V]
+	policy   policy[K, V]
+	lock     sync_utils.ReMutex
+	popCb    PopCallback[K, V]
+	opts     *options
+	closed   bool
+	stats    counters
 }
 
-func NewLRUCache[K comparable, V any](capacity int, popCb PopCallback[K, V]) *LRUCache[K, V] {
-	return &LRUCache[K, V]{
+func newBaseCache[K comparable, V any](capacity int, p policy[K, V], popCb PopCallback[K, V], opts []Option) baseCache[K, V] {
+	o := newOptions(opts)
+	return baseCache[K, V]{
 		capacity: capacity,
-		stack:    list.New(),
-		items:    make(map[K]*list.Element),
+		cost:     costFunc[K, V](o),
+		items:    make(map[K]*entry[K, V]),
+		policy:   p,
 		popCb:    popCb,
+		opts:     o,
+	}
+}
+
+// lookup 取未过期的条目, 过期的条目会被移除
+func (c *baseCache[K, V]) lookup(key K, now int64) (*entry[K, V], bool) {
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if e.expired(now) {
+		c.removeEntry(e, ReasonExpired)
+		return nil, false
 	}
+	return e, true
 }
 
-func (lru *LRUCache[K, V]) Get(key K) (value V, ok bool) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
-	if elem, ok := lru.items[key]; ok {
-		lru.stack.MoveToFront(elem)
-		return elem.Value.(*entry[K, V]).value, ok
+func (c *baseCache[K, V]) Get(key K) (value V, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if e, ok := c.lookup(key, time.Now().UnixNano()); ok {
+		c.policy.hit(e)
+		c.stats.hit(1)
+		return e.value, ok
 	}
+	c.stats.miss(1)
 	return
 }
 
-func (lru *LRUCache[K, V]) MultipleGet(keys []K) (values []V, miss []K) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) MultipleGet(keys []K) (values []V, miss []K) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
+	now := time.Now().UnixNano()
 	values = make([]V, 0, len(keys))
 	miss = make([]K, 0, len(keys))
 	for _, key := range keys {
-		if elem, ok := lru.items[key]; ok {
-			lru.stack.MoveToFront(elem)
-			values = append(values, elem.Value.(*entry[K, V]).value)
+		if e, ok := c.lookup(key, now); ok {
+			c.policy.hit(e)
+			values = append(values, e.value)
 		} else {
 			miss = append(miss, key)
 		}
 	}
+	c.stats.hit(len(values))
+	c.stats.miss(len(miss))
 	return
 }
 
-func (lru *LRUCache[K, V]) Peek(key K) (value V) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) Peek(key K) (value V) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	if elem, ok := lru.items[key]; ok {
-		return elem.Value.(*entry[K, V]).value
+	if e, ok := c.lookup(key, time.Now().UnixNano()); ok {
+		return e.value
 	}
 	return
 }
 
-func (lru *LRUCache[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) peek(key K) (value V, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	for cursor := lru.stack.Front(); cursor != nil; cursor = cursor.Next() {
-		elem := cursor.Value.(*entry[K, V])
-		if !f(elem.key, elem.value) {
-			break
-		}
+	if e, ok := c.lookup(key, time.Now().UnixNano()); ok {
+		return e.value, true
 	}
+	return
 }
 
-func (lru *LRUCache[K, V]) Contains(key K) bool {
-	_, ok := lru.Get(key)
+// TTL 条目剩余的存活时间, 不过期的条目返回 -1
+func (c *baseCache[K, V]) TTL(key K) (time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UnixNano()
+	e, ok := c.lookup(key, now)
+	if !ok {
+		return 0, false
+	}
+	if e.expire > 0 {
+		return time.Duration(e.expire - now), true
+	}
+	return -1, true
+}
+
+// Range 按保留优先级从高到低遍历(LRU 为最近使用的顺序), 跳过已过期的条目
+func (c *baseCache[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now().UnixNano()
+	c.policy.walk(func(e *entry[K, V]) bool {
+		if e.expired(now) {
+			return true
+		}
+		return f(e.key, e.value)
+	})
+}
+
+func (c *baseCache[K, V]) Contains(key K) bool {
+	_, ok := c.Get(key)
 	return ok
 }
 
-func (lru *LRUCache[K, V]) Set(key K, value V) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+// Set 使用默认存活时间写入
+func (c *baseCache[K, V]) Set(key K, value V) bool {
+	return c.SetWithTTL(key, value, c.opts.ttl)
+}
 
-	if elem, ok := lru.items[key]; ok {
-		lru.stack.MoveToFront(elem)
-		elem.Value.(*entry[K, V]).value = value
-		return
+// SetWithTTL 写入并指定存活时间, ttl <= 0 表示不过期. 成本超过容量时拒绝写入并返回 false, 缓存保持不变.
+// 容量为 0 时不拒绝, 写入后立即按 ReasonCapacity 淘汰并回调
+func (c *baseCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cost := c.cost(key, value)
+	if c.capacity > 0 && cost > int64(c.capacity) {
+		c.stats.reject()
+		return false
+	}
+	c.stats.set()
+	var expire int64
+	if ttl > 0 {
+		expire = time.Now().Add(ttl).UnixNano()
+	}
+
+	if e, ok := c.items[key]; ok {
+		c.policy.hit(e)
+		old := e.value
+		e.value = value
+		e.expire = expire
+		oldCost := e.cost
+		c.used += cost - oldCost
+		e.cost = cost
+		c.policy.recost(e, oldCost)
+		c.stats.evict(ReasonReplaced, 1)
+		if c.popCb != nil {
+			c.popCb(key, old, ReasonReplaced)
+		}
+		c.evictOverflow()
+		return true
 	}
 
-	elem := &entry[K, V]{
-		key:   key,
-		value: value,
+	e := &entry[K, V]{
+		key:    key,
+		value:  value,
+		expire: expire,
+		cost:   cost,
 	}
-	lru.items[key] = lru.stack.PushFront(elem)
-	if lru.capacity < 0 {
+	c.items[key] = e
+	c.used += cost
+	c.policy.add(e)
+	c.evictOverflow()
+	return true
+}
+
+// evictOverflow 淘汰条目直到总成本不超过容量
+func (c *baseCache[K, V]) evictOverflow() {
+	if c.capacity < 0 {
 		return
 	}
-	if lru.stack.Len() > lru.capacity {
-		lru.removeElement(lru.stack.Back())
+	for c.used > int64(c.capacity) {
+		e := c.policy.evict()
+		if e == nil {
+			return
+		}
+		c.drop(e, ReasonCapacity)
 	}
 }
 
-func (lru *LRUCache[K, V]) Remove(key K) bool {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) Remove(key K) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	if elem, ok := lru.items[key]; ok {
-		lru.removeElement(elem)
+	if e, ok := c.items[key]; ok {
+		c.removeEntry(e, ReasonRemoved)
 		return true
 	}
 	return false
 }
 
-func (lru *LRUCache[K, V]) removeElement(element *list.Element) {
-	lru.stack.Remove(element)
-	elem := element.Value.(*entry[K, V])
-	delete(lru.items, elem.key)
+func (c *baseCache[K, V]) removeEntry(e *entry[K, V], reason Reason) {
+	c.policy.remove(e)
+	c.drop(e, reason)
+}
 
-	if lru.popCb != nil {
-		lru.popCb(elem.key, elem.value)
+// drop 从索引中删除已离开策略的条目并回调
+func (c *baseCache[K, V]) drop(e *entry[K, V], reason Reason) {
+	delete(c.items, e.key)
+	c.used -= e.cost
+	c.stats.evict(reason, 1)
+	if c.popCb != nil {
+		c.popCb(e.key, e.value, reason)
 	}
 }
 
-func (lru *LRUCache[K, V]) ReCapacity(capacity int) {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+// RemoveExpired 移除所有过期条目, 返回移除的数量
+func (c *baseCache[K, V]) RemoveExpired() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	if capacity < 0 {
-		lru.capacity = -1
+	now := time.Now().UnixNano()
+	var expired []*entry[K, V]
+	for _, e := range c.items {
+		if e.expired(now) {
+			expired = append(expired, e)
+		}
+	}
+	count := 0
+	for _, e := range expired {
+		// 回调中可能已经移除了其他条目
+		if c.items[e.key] == e {
+			c.removeEntry(e, ReasonExpired)
+			count++
+		}
+	}
+	return count
+}
+
+func (c *baseCache[K, V]) startSweeper() {
+	if c.opts.sweep <= 0 || c.opts.wheel == nil {
 		return
 	}
-	if capacity > lru.capacity && lru.capacity >= 0 {
-		lru.capacity = capacity
+	c.opts.wheel.AddTimerCustom(c.opts.sweep, c, nil, c.sweep)
+}
+
+func (c *baseCache[K, V]) sweep(interface{}) {
+	c.RemoveExpired()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.closed {
+		c.startSweeper()
+	}
+}
+
+// Close 停止主动清理
+func (c *baseCache[K, V]) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.closed {
 		return
 	}
+	c.closed = true
+	if c.opts.sweep > 0 && c.opts.wheel != nil {
+		c.opts.wheel.RemoveTimer(c)
+	}
+}
 
-	lru.capacity = capacity
-	step := lru.stack.Len() - capacity
-	for i := 0; i < step; i++ {
-		lru.removeElement(lru.stack.Back())
+func (c *baseCache[K, V]) ReCapacity(capacity int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if capacity < 0 {
+		capacity = -1
 	}
+	c.capacity = capacity
+	c.policy.resize(capacity)
+	c.evictOverflow()
+}
+
+// Size 条目数量, 包含尚未清理的过期条目
+func (c *baseCache[K, V]) Size() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.items)
 }
 
-func (lru *LRUCache[K, V]) Size() int {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) Capacity() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	return lru.stack.Len()
+	return c.capacity
 }
 
-func (lru *LRUCache[K, V]) Capacity() int {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+// Cost 条目成本之和, 未设置 WithCost 时与 Size 相同
+func (c *baseCache[K, V]) Cost() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	return lru.capacity
+	return c.used
 }
 
-func (lru *LRUCache[K, V]) FlushAll() {
-	lru.lock.Lock()
-	defer lru.lock.Unlock()
+func (c *baseCache[K, V]) FlushAll() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 
-	if lru.popCb != nil {
-		for key, value := range lru.items {
-			lru.popCb(key, value.Value.(*entry[K, V]).value)
+	c.stats.evict(ReasonFlushed, len(c.items))
+	if c.popCb != nil {
+		for key, e := range c.items {
+			c.popCb(key, e.value, ReasonFlushed)
 		}
 	}
-	lru.items = make(map[K]*list.Element)
-	lru.stack.Init()
+	c.items = make(map[K]*entry[K, V])
+	c.used = 0
+	c.policy.reset()
+}
+
+// Stats 统计快照
+func (c *baseCache[K, V]) Stats() Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s := c.stats.snapshot()
+	s.Size, s.Capacity, s.Cost = len(c.items), c.capacity, c.used
+	return s
+}
+
+// ResetStats 清零计数, 不影响缓存的条目
+func (c *baseCache[K, V]) ResetStats() {
+	c.stats.reset()
 }
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+var policies = []Policy{PolicyLRU, PolicyLFU, PolicyARC, PolicyTinyLFU, PolicyShardedLRU}
+
+func TestCacheCost(t *testing.T) {
+	for _, policy := range policies {
+		c, err := New[int, []byte](policy, 1000, nil, WithCost(func(_ int, data []byte) int64 { return int64(len(data)) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 5000; i++ {
+			key := r.Intn(500)
+			if r.Intn(4) == 0 {
+				c.Get(key)
+				continue
+			}
+			if !c.Set(key, make([]byte, r.Intn(100))) {
+				t.Fatalf("%s: rejected an entry within the capacity", policy)
+			}
+			if c.Cost() > 1000 {
+				t.Fatalf("%s: cost %d exceeds the capacity", policy, c.Cost())
+			}
+		}
+
+		var cost int64
+		c.Range(func(_ int, data []byte) bool {
+			cost += int64(len(data))
+			return true
+		})
+		if cost != c.Cost() {
+			t.Fatalf("%s: entries cost %d, Cost() = %d", policy, cost, c.Cost())
+		}
+		if c.Set(-1, make([]byte, 1001)) {
+			t.Fatalf("%s: accepted an entry over the capacity", policy)
+		}
+
+		c.ReCapacity(100)
+		if c.Cost() > 100 {
+			t.Fatalf("%s: cost %d exceeds the capacity after ReCapacity", policy, c.Cost())
+		}
+	}
+}
+
+func TestCacheZeroCapacity(t *testing.T) {
+	for _, policy := range policies {
+		var pops []Reason
+		c, err := New[int, string](policy, 0, func(_ int, _ string, reason Reason) {
+			pops = append(pops, reason)
+		}, WithCost(func(_ int, value string) int64 { return int64(len(value)) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 与不计成本时一样写入后立即淘汰, 不按超出容量拒绝
+		if !c.Set(1, "abc") {
+			t.Fatalf("%s: rejected a write to a zero-capacity cache", policy)
+		}
+		if c.Size() != 0 || c.Cost() != 0 {
+			t.Fatalf("%s: size %d cost %d, want 0", policy, c.Size(), c.Cost())
+		}
+		if len(pops) != 1 || pops[0] != ReasonCapacity {
+			t.Fatalf("%s: callbacks %v, want one capacity eviction", policy, pops)
+		}
+	}
+}
+
+func TestARCUnboundedTarget(t *testing.T) {
+	c := NewARCCache[int, int](2, nil)
+	p := c.policy.(*arcPolicy[int, int])
+	c.Set(1, 1)
+	c.Set(2, 2)
+	c.Get(1)
+	c.Get(2)
+	c.Set(3, 3)
+	// 3 被淘汰到 B1, 不限容量后再次写入命中幽灵记录
+	if len(p.g1) != 1 {
+		t.Fatalf("B1 has %d ghosts, want 1", len(p.g1))
+	}
+	c.ReCapacity(-1)
+	c.Set(3, 3)
+	if p.target < 0 {
+		t.Fatalf("target = %d after a ghost hit while unbounded", p.target)
+	}
+	p.target = -1
+	c.ReCapacity(2)
+	if p.target < 0 || p.target > 2 {
+		t.Fatalf("target = %d, want within [0, 2]", p.target)
+	}
+	for i := 10; i < 20; i++ {
+		c.Set(i, i)
+		c.Get(i - 1)
+	}
+	if c.Size() != 2 {
+		t.Fatalf("size = %d, want 2", c.Size())
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	if _, err := New[int, string]("fifo", 10, nil); err == nil {
+		t.Fatal("New accepted an unknown policy")
+	}
+	for _, policy := range policies {
+		_, err := New[int, string](policy, 10, nil, WithCost(func(int, []byte) int64 { return 1 }))
+		if !errors.Is(err, ErrCostType) {
+			t.Fatalf("%s: err = %v, want ErrCostType", policy, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"container/list"
+	"sort"
+)
+
+// LFUCache 淘汰访问频次最低的条目, 频次相同时淘汰最久未使用的
+type LFUCache[K comparable, V any] struct {
+	baseCache[K, V]
+}
+
+func NewLFUCache[K comparable, V any](capacity int, popCb PopCallback[K, V], opts ...Option) *LFUCache[K, V] {
+	lfu := &LFUCache[K, V]{
+		baseCache: newBaseCache[K, V](capacity, newLFUPolicy[K, V](), popCb, opts),
+	}
+	lfu.startSweeper()
+	return lfu
+}
+
+type lfuPolicy[K comparable, V any] struct {
+	freqs   map[int]*list.List // 频次 -> 该频次的条目, 表头为最近使用
+	minFreq int
+}
+
+func newLFUPolicy[K comparable, V any]() *lfuPolicy[K, V] {
+	return &lfuPolicy[K, V]{freqs: make(map[int]*list.List)}
+}
+
+func (p *lfuPolicy[K, V]) push(e *entry[K, V]) {
+	l, ok := p.freqs[e.freq]
+	if !ok {
+		l = list.New()
+		p.freqs[e.freq] = l
+	}
+	e.elem = l.PushFront(e)
+}
+
+// unlink 从频次链表中移除, 返回该频次是否已空
+func (p *lfuPolicy[K, V]) unlink(e *entry[K, V]) bool {
+	l := p.freqs[e.freq]
+	l.Remove(e.elem)
+	if l.Len() > 0 {
+		return false
+	}
+	delete(p.freqs, e.freq)
+	return true
+}
+
+func (p *lfuPolicy[K, V]) add(e *entry[K, V]) {
+	e.freq = 1
+	p.push(e)
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy[K, V]) hit(e *entry[K, V]) {
+	if p.unlink(e) && p.minFreq == e.freq {
+		p.minFreq++
+	}
+	e.freq++
+	p.push(e)
+}
+
+func (p *lfuPolicy[K, V]) remove(e *entry[K, V]) {
+	if p.unlink(e) && p.minFreq == e.freq {
+		p.minFreq = p.lowest()
+	}
+}
+
+func (p *lfuPolicy[K, V]) recost(*entry[K, V], int64) {}
+
+func (p *lfuPolicy[K, V]) lowest() int {
+	lowest := 0
+	for freq := range p.freqs {
+		if lowest == 0 || freq < lowest {
+			lowest = freq
+		}
+	}
+	return lowest
+}
+
+func (p *lfuPolicy[K, V]) evict() *entry[K, V] {
+	l, ok := p.freqs[p.minFreq]
+	if !ok {
+		if p.minFreq = p.lowest(); p.minFreq == 0 {
+			return nil
+		}
+		l = p.freqs[p.minFreq]
+	}
+	e := l.Back().Value.(*entry[K, V])
+	p.remove(e)
+	return e
+}
+
+func (p *lfuPolicy[K, V]) walk(f func(e *entry[K, V]) bool) {
+	freqs := make([]int, 0, len(p.freqs))
+	for freq := range p.freqs {
+		freqs = append(freqs, freq)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(freqs)))
+	for _, freq := range freqs {
+		for cursor := p.freqs[freq].Front(); cursor != nil; cursor = cursor.Next() {
+			if !f(cursor.Value.(*entry[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+func (p *lfuPolicy[K, V]) resize(int) {}
+
+func (p *lfuPolicy[K, V]) reset() {
+	p.freqs = make(map[int]*list.List)
+	p.minFreq = 0
+}
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/youngpto/funs_tool/async"
+	"sync"
+	"time"
+)
+
+/*
+	players := utils.NewLoadingCache[int64, *Player](
+		utils.NewLRUCache[int64, *Player](5000, nil, utils.WithTTL(10*time.Minute)),
+		utils.WithRefreshAhead(time.Minute),
+		utils.WithErrorTTL(5*time.Second),
+	)
+	player, err := players.GetOrLoad(ctx, uid, loadPlayer)
+
+同一个键并发未命中时只调用一次加载函数, 其余调用者等待同一个结果. 加载成功的值通过 Set 写入, 使用缓存的默认存活时间.
+通过 LoadingCache 的 Set/Remove 写入或移除时, 之前开始的加载结果不会覆盖缓存. 写入缓存时持有 LoadingCache 的锁,
+底层缓存的淘汰回调中不能再调用 LoadingCache 的方法.
+*/
+
+// ErrNotFound 加载函数用于表示键不存在, 开启 WithErrorTTL 时同样会被缓存
+var ErrNotFound = errors.New("cache: key not found")
+
+// Loader 加载单个键
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// BatchLoader 批量加载, 结果中没有的键视为 ErrNotFound
+type BatchLoader[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// LoadingCache 在 Cache 之上合并并发加载, 支持提前刷新与错误缓存
+type LoadingCache[K comparable, V any] struct {
+	Cache[K, V]
+	mu    sync.Mutex
+	calls map[K]*call[V]
+	errs  map[K]loadError
+	opts  *options
+	stats counters
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+	stale bool // 加载期间键被写入或移除, 结果不再写入缓存
+}
+
+type loadError struct {
+	err    error
+	expire time.Time
+}
+
+func NewLoadingCache[K comparable, V any](cache Cache[K, V], opts ...Option) *LoadingCache[K, V] {
+	return &LoadingCache[K, V]{
+		Cache: cache,
+		calls: make(map[K]*call[V]),
+		errs:  make(map[K]loadError),
+		opts:  newOptions(opts),
+	}
+}
+
+// GetOrLoad 命中时直接返回, 未命中时加载并写入缓存. 加载在后台进行, 使用不随调用者取消的 ctx,
+// 调用者的 ctx 结束只会停止等待, 加载会继续完成并写入缓存
+func (lc *LoadingCache[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	if value, ok := lc.Cache.Get(key); ok {
+		lc.refreshAhead(key, loader)
+		return value, nil
+	}
+
+	lc.mu.Lock()
+	c, owner, err := lc.begin(key)
+	lc.mu.Unlock()
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	if owner {
+		async.Go(func() {
+			lc.run(detach(ctx), key, loader, c, false)
+		})
+	}
+	return wait(ctx, c)
+}
+
+// MultipleGetOrLoad 批量获取, 未命中的键合并为一次批量加载. 不存在的键不会出现在结果中, 返回遇到的第一个其他错误
+func (lc *LoadingCache[K, V]) MultipleGetOrLoad(ctx context.Context, keys []K, loader BatchLoader[K, V]) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	var miss []K
+	for _, key := range keys {
+		if value, ok := lc.Cache.Get(key); ok {
+			result[key] = value
+		} else {
+			miss = append(miss, key)
+		}
+	}
+
+	var firstErr error
+	calls := make(map[K]*call[V], len(miss))
+	var owned []K
+	lc.mu.Lock()
+	for _, key := range miss {
+		if _, ok := calls[key]; ok {
+			continue
+		}
+		c, owner, err := lc.begin(key)
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, ErrNotFound) {
+				firstErr = err
+			}
+			continue
+		}
+		calls[key] = c
+		if owner {
+			owned = append(owned, key)
+		}
+	}
+	lc.mu.Unlock()
+
+	if len(owned) > 0 {
+		loadCtx := detach(ctx)
+		async.Go(func() {
+			loaded, err := lc.batch(loadCtx, owned, loader)
+			for _, key := range owned {
+				c := calls[key]
+				switch value, ok := loaded[key]; {
+				case err != nil:
+					c.err = err
+				case ok:
+					c.value = value
+				default:
+					c.err = ErrNotFound
+				}
+				lc.finish(key, c, false)
+			}
+		})
+	}
+
+	for key, c := range calls {
+		value, err := wait(ctx, c)
+		if err == nil {
+			result[key] = value
+		} else if firstErr == nil && !errors.Is(err, ErrNotFound) {
+			firstErr = err
+		}
+	}
+	return result, firstErr
+}
+
+// begin 加入进行中的加载或登记新的加载, 需持有 mu. 错误仍在缓存期内时返回该错误.
+// 未命中之后、取得锁之前其他加载可能已经写入缓存, 这时返回已完成的 call
+func (lc *LoadingCache[K, V]) begin(key K) (*call[V], bool, error) {
+	if value, ok := lc.peek(key); ok {
+		c := &call[V]{done: make(chan struct{}), value: value}
+		close(c.done)
+		return c, false, nil
+	}
+	if e, ok := lc.errs[key]; ok {
+		if time.Now().Before(e.expire) {
+			return nil, false, e.err
+		}
+		delete(lc.errs, key)
+	}
+	if c, ok := lc.calls[key]; ok {
+		return c, false, nil
+	}
+	c := &call[V]{done: make(chan struct{})}
+	lc.calls[key] = c
+	return c, true, nil
+}
+
+// peek 不计入统计地查看缓存, 底层缓存不支持时退回 Get
+func (lc *LoadingCache[K, V]) peek(key K) (V, bool) {
+	if p, ok := lc.Cache.(peeker[K, V]); ok {
+		return p.peek(key)
+	}
+	return lc.Cache.Get(key)
+}
+
+func (lc *LoadingCache[K, V]) run(ctx context.Context, key K, loader Loader[K, V], c *call[V], refresh bool) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("cache: loader panic: %v", r)
+		}
+		lc.stats.load(time.Since(start), c.err)
+		lc.finish(key, c, refresh)
+	}()
+	c.value, c.err = loader(ctx, key)
+}
+
+func (lc *LoadingCache[K, V]) batch(ctx context.Context, keys []K, loader BatchLoader[K, V]) (loaded map[K]V, err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cache: loader panic: %v", r)
+		}
+		lc.stats.load(time.Since(start), err)
+	}()
+	return loader(ctx, keys)
+}
+
+// finish 写入结果并唤醒等待者, 刷新失败时保留旧值且不缓存错误, ctx 的取消与超时也不缓存.
+// 加载期间键被写入或移除时结果只返回给等待者
+func (lc *LoadingCache[K, V]) finish(key K, c *call[V], refresh bool) {
+	lc.mu.Lock()
+	if !c.stale {
+		delete(lc.calls, key)
+		if c.err == nil {
+			lc.Cache.Set(key, c.value)
+		} else if !refresh && lc.opts.errorTTL > 0 && !isCtxErr(c.err) {
+			lc.errs[key] = loadError{err: c.err, expire: time.Now().Add(lc.opts.errorTTL)}
+		}
+	}
+	lc.mu.Unlock()
+	close(c.done)
+}
+
+// invalidate 清除缓存的错误, 进行中的加载结果不再写入缓存, 需持有 mu
+func (lc *LoadingCache[K, V]) invalidate(key K) {
+	delete(lc.errs, key)
+	if c, ok := lc.calls[key]; ok {
+		c.stale = true
+		delete(lc.calls, key)
+	}
+}
+
+// Set 写入缓存, 在此之前开始的加载不会覆盖写入的值
+func (lc *LoadingCache[K, V]) Set(key K, value V) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.invalidate(key)
+	return lc.Cache.Set(key, value)
+}
+
+func (lc *LoadingCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.invalidate(key)
+	return lc.Cache.SetWithTTL(key, value, ttl)
+}
+
+// Remove 移除条目, 在此之前开始的加载不会再写回
+func (lc *LoadingCache[K, V]) Remove(key K) bool {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.invalidate(key)
+	return lc.Cache.Remove(key)
+}
+
+// refreshAhead 条目即将过期时在后台重新加载
+func (lc *LoadingCache[K, V]) refreshAhead(key K, loader Loader[K, V]) {
+	if lc.opts.refresh <= 0 {
+		return
+	}
+	if ttl, ok := lc.Cache.TTL(key); !ok || ttl < 0 || ttl > lc.opts.refresh {
+		return
+	}
+
+	lc.mu.Lock()
+	if _, ok := lc.calls[key]; ok {
+		lc.mu.Unlock()
+		return
+	}
+	c := &call[V]{done: make(chan struct{})}
+	lc.calls[key] = c
+	lc.mu.Unlock()
+
+	async.Go(func() {
+		lc.run(context.Background(), key, loader, c, true)
+	})
+}
+
+// Forget 清除键上缓存的加载错误, 进行中的加载结果不再写入缓存
+func (lc *LoadingCache[K, V]) Forget(key K) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.invalidate(key)
+}
+
+// Stats 底层缓存的统计加上加载的次数、失败次数与耗时
+func (lc *LoadingCache[K, V]) Stats() Stats {
+	s := lc.Cache.Stats()
+	loads := lc.stats.snapshot()
+	s.Loads, s.LoadErrors, s.LoadTime = loads.Loads, loads.LoadErrors, loads.LoadTime
+	return s
+}
+
+func (lc *LoadingCache[K, V]) ResetStats() {
+	lc.Cache.ResetStats()
+	lc.stats.reset()
+}
+
+func isCtxErr(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// detached 保留 ctx 中的值, 但不会被取消也没有截止时间, 合并的加载不受发起者取消的影响
+type detached struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return detached{parent: ctx}
+}
+
+func (detached) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detached) Done() <-chan struct{} {
+	return nil
+}
+
+func (detached) Err() error {
+	return nil
+}
+
+func (d detached) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+func wait[V any](ctx context.Context, c *call[V]) (V, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case <-c.done:
+		return c.value, c.err
+	default:
+	}
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
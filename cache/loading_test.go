@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadingCacheCallerCancel(t *testing.T) {
+	lc := NewLoadingCache[int, string](NewLRUCache[int, string](10, nil), WithErrorTTL(time.Minute))
+	var loads int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key int) (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "v", nil
+	}
+
+	// 发起加载的调用者已取消, 只是自己停止等待, 加载继续完成
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lc.GetOrLoad(ctx, 1, loader); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller err = %v, want context.Canceled", err)
+	}
+	close(release)
+	value, err := lc.GetOrLoad(context.Background(), 1, loader)
+	if err != nil || value != "v" {
+		t.Fatalf("GetOrLoad = %q %v, want v", value, err)
+	}
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Fatalf("loads = %d, want 1", n)
+	}
+}
+
+func TestLoadingCacheSkipsCtxErrors(t *testing.T) {
+	lc := NewLoadingCache[int, string](NewLRUCache[int, string](10, nil), WithErrorTTL(time.Minute))
+	var loads int32
+	loader := func(ctx context.Context, key int) (string, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			return "", context.DeadlineExceeded
+		}
+		return "v", nil
+	}
+
+	if _, err := lc.GetOrLoad(context.Background(), 1, loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("first err = %v, want context.DeadlineExceeded", err)
+	}
+	value, err := lc.GetOrLoad(context.Background(), 1, loader)
+	if err != nil || value != "v" {
+		t.Fatalf("GetOrLoad = %q %v, want v", value, err)
+	}
+
+	// 其他错误仍然在 errorTTL 内缓存
+	errBoom := errors.New("boom")
+	if _, err := lc.GetOrLoad(context.Background(), 2, func(context.Context, int) (string, error) {
+		return "", errBoom
+	}); !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v, want boom", err)
+	}
+	if _, err := lc.GetOrLoad(context.Background(), 2, loader); !errors.Is(err, errBoom) {
+		t.Fatalf("cached err = %v, want boom", err)
+	}
+}
+
+func TestLoadingCacheMultipleCallerCancel(t *testing.T) {
+	lc := NewLoadingCache[int, string](NewLRUCache[int, string](10, nil), WithErrorTTL(time.Minute))
+	loader := func(ctx context.Context, keys []int) (map[int]string, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result := make(map[int]string, len(keys))
+		for _, key := range keys {
+			result[key] = "v"
+		}
+		return result, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lc.MultipleGetOrLoad(ctx, []int{1, 2}, loader)
+	result, err := lc.MultipleGetOrLoad(context.Background(), []int{1, 2}, loader)
+	if err != nil || len(result) != 2 {
+		t.Fatalf("MultipleGetOrLoad = %v %v, want both keys", result, err)
+	}
+}
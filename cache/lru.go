@@ -0,0 +1,56 @@
+package utils
+
+import "container/list"
+
+// LRUCache 淘汰最久未使用的条目
+type LRUCache[K comparable, V any] struct {
+	baseCache[K, V]
+}
+
+func NewLRUCache[K comparable, V any](capacity int, popCb PopCallback[K, V], opts ...Option) *LRUCache[K, V] {
+	lru := &LRUCache[K, V]{
+		baseCache: newBaseCache[K, V](capacity, &lruPolicy[K, V]{stack: list.New()}, popCb, opts),
+	}
+	lru.startSweeper()
+	return lru
+}
+
+type lruPolicy[K comparable, V any] struct {
+	stack *list.List
+}
+
+func (p *lruPolicy[K, V]) add(e *entry[K, V]) {
+	e.elem = p.stack.PushFront(e)
+}
+
+func (p *lruPolicy[K, V]) hit(e *entry[K, V]) {
+	p.stack.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy[K, V]) remove(e *entry[K, V]) {
+	p.stack.Remove(e.elem)
+}
+
+func (p *lruPolicy[K, V]) recost(*entry[K, V], int64) {}
+
+func (p *lruPolicy[K, V]) evict() *entry[K, V] {
+	back := p.stack.Back()
+	if back == nil {
+		return nil
+	}
+	return p.stack.Remove(back).(*entry[K, V])
+}
+
+func (p *lruPolicy[K, V]) walk(f func(e *entry[K, V]) bool) {
+	for cursor := p.stack.Front(); cursor != nil; cursor = cursor.Next() {
+		if !f(cursor.Value.(*entry[K, V])) {
+			return
+		}
+	}
+}
+
+func (p *lruPolicy[K, V]) resize(int) {}
+
+func (p *lruPolicy[K, V]) reset() {
+	p.stack.Init()
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"errors"
+	"github.com/youngpto/funs_tool/algorithm"
+	"time"
+)
+
+type Option func(opts *options)
+
+type options struct {
+	ttl    time.Duration
+	sweep  time.Duration
+	wheel  *algorithm.TimeWheel
+	shards int
+	sample uint32
+
+	refresh  time.Duration
+	errorTTL time.Duration
+
+	cost interface{} // func(K, V) int64
+
+	flush      time.Duration
+	batch      int
+	retries    int
+	retryDelay time.Duration
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		shards:     16,
+		sample:     8,
+		batch:      100,
+		retries:    3,
+		retryDelay: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.wheel == nil {
+		o.wheel = algorithm.GetTimeWheel()
+	}
+	return o
+}
+
+// WithTTL 条目的默认存活时间, 默认不过期
+func WithTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.ttl = ttl
+	}
+}
+
+// WithSweeper 每隔 interval 主动清理一次过期条目, 默认只在访问时惰性清理
+func WithSweeper(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.sweep = interval
+	}
+}
+
+// WithTimeWheel 主动清理使用的时间轮, 需已启动, 默认 algorithm.GetTimeWheel()
+func WithTimeWheel(wheel *algorithm.TimeWheel) Option {
+	return func(opts *options) {
+		opts.wheel = wheel
+	}
+}
+
+// WithShards ShardedLRUCache 的分片数, 默认 16. 容量较小时实际的分片数会减少, 保证每个分片至少 minShardCapacity
+func WithShards(n int) Option {
+	return func(opts *options) {
+		if n > 0 {
+			opts.shards = n
+		}
+	}
+}
+
+// WithReadSampling ShardedLRUCache 每 n 次命中才更新一次最近使用顺序, 其余读取只加读锁. 默认 8, 传 1 时每次都更新,
+// 淘汰顺序与 LRUCache 相同, 但每次读取都要加写锁
+func WithReadSampling(n int) Option {
+	return func(opts *options) {
+		if n > 0 {
+			opts.sample = uint32(n)
+		}
+	}
+}
+
+// WithRefreshAhead LoadingCache 命中的条目剩余存活时间不足 window 时在后台重新加载, 期间仍返回旧值
+func WithRefreshAhead(window time.Duration) Option {
+	return func(opts *options) {
+		opts.refresh = window
+	}
+}
+
+// WithErrorTTL LoadingCache 加载失败后在 ttl 内直接返回同一个错误, 不再调用加载函数. 默认不缓存错误
+func WithErrorTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.errorTTL = ttl
+	}
+}
+
+// WithWriteBehind StoreCache 使用异步写入, 每隔 interval 批量写入一次脏数据. 默认同步写入(write-through)
+func WithWriteBehind(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.flush = interval
+	}
+}
+
+// WithFlushBatch StoreCache 异步写入时每批的最大条目数, 脏数据达到该数量时提前写入. 默认 100
+func WithFlushBatch(n int) Option {
+	return func(opts *options) {
+		if n > 0 {
+			opts.batch = n
+		}
+	}
+}
+
+// WithRetry StoreCache 异步写入失败时的重试次数与间隔, 重试间隔逐次翻倍. 默认重试 3 次, 间隔 100ms
+func WithRetry(times int, delay time.Duration) Option {
+	return func(opts *options) {
+		if times >= 0 {
+			opts.retries = times
+		}
+		opts.retryDelay = delay
+	}
+}
+
+// WithCost 条目的成本, 设置后容量表示总成本的上限(如字节数), 默认每个条目成本为 1.
+// 成本超过容量的单个条目会被 Set 拒绝. ARCCache 与 TinyLFUCache 的各区域同样按成本划分.
+// K、V 需与缓存的键值类型一致, 否则 New 返回 ErrCostType, 各策略的构造函数 panic
+func WithCost[K comparable, V any](cost func(key K, value V) int64) Option {
+	return func(opts *options) {
+		opts.cost = cost
+	}
+}
+
+// ErrCostType WithCost 的键值类型与缓存不一致
+var ErrCostType = errors.New("cache: cost function does not match key and value types")
+
+// checkOptions 检查 WithCost 的键值类型, 不创建时间轮等依赖
+func checkOptions[K comparable, V any](opts []Option) error {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.cost == nil {
+		return nil
+	}
+	if _, ok := o.cost.(func(key K, value V) int64); !ok {
+		return ErrCostType
+	}
+	return nil
+}
+
+// costFunc 取出成本函数, 负数成本按 0 计算
+func costFunc[K comparable, V any](opts *options) func(key K, value V) int64 {
+	if opts.cost == nil {
+		return func(K, V) int64 {
+			return 1
+		}
+	}
+	cost, ok := opts.cost.(func(key K, value V) int64)
+	if !ok {
+		panic(ErrCostType.Error())
+	}
+	return func(key K, value V) int64 {
+		if n := cost(key, value); n > 0 {
+			return n
+		}
+		return 0
+	}
+}
@@ -0,0 +1,466 @@
+package utils
+
+import (
+	"container/list"
+	"github.com/youngpto/funs_tool/math_utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardedLRUCache 按键哈希分片的 LRU, 每个分片使用普通的读写锁, 接口与 LRUCache 相同.
+// 容量平均分给各分片, 各分片容量之和等于 capacity, 淘汰与 Range 的顺序只在分片内有效, 键分布不均时会在总量未满前提前淘汰.
+// 容量较小时减少分片数, 每个分片至少 minShardCapacity, 分片数在创建后不再改变. 设置 WithCost 时单个条目的成本不能超过总容量,
+// 超过分片容量的条目独占所在分片, 并从其他分片淘汰最久未使用的条目, 使总成本不超过容量.
+// 回调在释放分片锁之后执行, 回调中可以再访问缓存
+type ShardedLRUCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int64 // 原子读写
+	used     int64 // 各分片成本之和, 原子读写
+	closed   bool
+	next     uint32 // 下一次跨分片淘汰的起始分片
+	shards   []*lruShard[K, V]
+	hasher   hasher[K]
+	cost     func(key K, value V) int64
+	popCb    PopCallback[K, V]
+	opts     *options
+	stats    counters
+}
+
+type popped[K comparable, V any] struct {
+	key    K
+	value  V
+	reason Reason
+}
+
+type lruShard[K comparable, V any] struct {
+	mu       sync.RWMutex
+	capacity int
+	used     int64
+	total    *int64 // 所属缓存的总成本
+	stack    *list.List
+	items    map[K]*entry[K, V]
+	reads    uint32
+}
+
+// minShardCapacity 分片的最小容量, 容量过小时分片越多提前淘汰越明显
+const minShardCapacity = 64
+
+func NewShardedLRUCache[K comparable, V any](capacity int, popCb PopCallback[K, V], opts ...Option) *ShardedLRUCache[K, V] {
+	c := &ShardedLRUCache[K, V]{
+		capacity: int64(capacity),
+		hasher:   newHasher[K](),
+		popCb:    popCb,
+		opts:     newOptions(opts),
+	}
+	c.cost = costFunc[K, V](c.opts)
+	c.shards = make([]*lruShard[K, V], shardCount(capacity, c.opts.shards))
+	for i := range c.shards {
+		c.shards[i] = &lruShard[K, V]{
+			capacity: shardCapacity(capacity, len(c.shards), i),
+			total:    &c.used,
+			stack:    list.New(),
+			items:    make(map[K]*entry[K, V]),
+		}
+	}
+	c.startSweeper()
+	return c
+}
+
+func shardCount(capacity int, n int) int {
+	if capacity >= 0 && capacity/minShardCapacity < n {
+		return math_utils.Max(capacity/minShardCapacity, 1)
+	}
+	return n
+}
+
+// shardCapacity 第 i 个分片的容量, 余数分给前面的分片
+func shardCapacity(capacity int, n int, i int) int {
+	if capacity < 0 {
+		return -1
+	}
+	if i < capacity%n {
+		return capacity/n + 1
+	}
+	return capacity / n
+}
+
+func (c *ShardedLRUCache[K, V]) shard(key K) *lruShard[K, V] {
+	return c.shards[c.hasher.hash(key)%uint64(len(c.shards))]
+}
+
+func (c *ShardedLRUCache[K, V]) emit(pops []popped[K, V]) {
+	for _, pop := range pops {
+		c.stats.evict(pop.reason, 1)
+	}
+	if c.popCb == nil {
+		return
+	}
+	for _, pop := range pops {
+		c.popCb(pop.key, pop.value, pop.reason)
+	}
+}
+
+func (c *ShardedLRUCache[K, V]) get(key K, now int64, promote bool) (value V, ok bool) {
+	s := c.shard(key)
+	if promote && c.opts.sample <= 1 {
+		s.mu.Lock()
+		e, found := s.items[key]
+		if found && !e.expired(now) {
+			s.stack.MoveToFront(e.elem)
+			value = e.value
+			s.mu.Unlock()
+			return value, true
+		}
+		var pops []popped[K, V]
+		if found {
+			pops = s.remove(e, ReasonExpired, pops)
+		}
+		s.mu.Unlock()
+		c.emit(pops)
+		return
+	}
+
+	s.mu.RLock()
+	e, found := s.items[key]
+	if !found {
+		s.mu.RUnlock()
+		return
+	}
+	if e.expired(now) {
+		s.mu.RUnlock()
+		s.mu.Lock()
+		var pops []popped[K, V]
+		if s.items[key] == e {
+			pops = s.remove(e, ReasonExpired, pops)
+		}
+		s.mu.Unlock()
+		c.emit(pops)
+		return
+	}
+	value = e.value
+	s.mu.RUnlock()
+
+	if promote && atomic.AddUint32(&s.reads, 1)%c.opts.sample == 0 {
+		s.mu.Lock()
+		if s.items[key] == e {
+			s.stack.MoveToFront(e.elem)
+		}
+		s.mu.Unlock()
+	}
+	return value, true
+}
+
+func (s *lruShard[K, V]) remove(e *entry[K, V], reason Reason, pops []popped[K, V]) []popped[K, V] {
+	s.stack.Remove(e.elem)
+	delete(s.items, e.key)
+	s.charge(-e.cost)
+	return append(pops, popped[K, V]{key: e.key, value: e.value, reason: reason})
+}
+
+// evictOverflow 淘汰到不超过分片容量, 最近写入的条目即使超出也会保留, 由 trim 保证总容量. 容量为 0 时全部淘汰
+func (s *lruShard[K, V]) evictOverflow(pops []popped[K, V]) []popped[K, V] {
+	keep := 1
+	if s.capacity == 0 {
+		keep = 0
+	}
+	for s.capacity >= 0 && s.used > int64(s.capacity) && s.stack.Len() > keep {
+		pops = s.remove(s.stack.Back().Value.(*entry[K, V]), ReasonCapacity, pops)
+	}
+	return pops
+}
+
+func (s *lruShard[K, V]) charge(cost int64) {
+	s.used += cost
+	atomic.AddInt64(s.total, cost)
+}
+
+// overflow 总成本是否超出容量, 只在有分片保留了超出自身容量的条目时发生
+func (c *ShardedLRUCache[K, V]) overflow() bool {
+	capacity := atomic.LoadInt64(&c.capacity)
+	return capacity >= 0 && atomic.LoadInt64(&c.used) > capacity
+}
+
+// trim 从 skip 以外的分片依次淘汰最久未使用的条目, 直到总成本不超过容量
+func (c *ShardedLRUCache[K, V]) trim(skip *lruShard[K, V]) {
+	capacity := atomic.LoadInt64(&c.capacity)
+	if capacity < 0 {
+		return
+	}
+	over := atomic.LoadInt64(&c.used) - capacity
+	start := int(atomic.AddUint32(&c.next, 1))
+	for i := 0; i < len(c.shards) && over > 0; i++ {
+		s := c.shards[(start+i)%len(c.shards)]
+		if s == skip {
+			continue
+		}
+		var pops []popped[K, V]
+		s.mu.Lock()
+		for over > 0 && s.stack.Len() > 0 {
+			e := s.stack.Back().Value.(*entry[K, V])
+			over -= e.cost
+			pops = s.remove(e, ReasonCapacity, pops)
+		}
+		s.mu.Unlock()
+		c.emit(pops)
+	}
+}
+
+func (c *ShardedLRUCache[K, V]) Get(key K) (value V, ok bool) {
+	value, ok = c.get(key, time.Now().UnixNano(), true)
+	if ok {
+		c.stats.hit(1)
+	} else {
+		c.stats.miss(1)
+	}
+	return
+}
+
+func (c *ShardedLRUCache[K, V]) MultipleGet(keys []K) (values []V, miss []K) {
+	now := time.Now().UnixNano()
+	values = make([]V, 0, len(keys))
+	miss = make([]K, 0, len(keys))
+	for _, key := range keys {
+		if value, ok := c.get(key, now, true); ok {
+			values = append(values, value)
+		} else {
+			miss = append(miss, key)
+		}
+	}
+	c.stats.hit(len(values))
+	c.stats.miss(len(miss))
+	return
+}
+
+func (c *ShardedLRUCache[K, V]) Peek(key K) (value V) {
+	value, _ = c.get(key, time.Now().UnixNano(), false)
+	return
+}
+
+func (c *ShardedLRUCache[K, V]) peek(key K) (V, bool) {
+	return c.get(key, time.Now().UnixNano(), false)
+}
+
+// TTL 条目剩余的存活时间, 不过期的条目返回 -1
+func (c *ShardedLRUCache[K, V]) TTL(key K) (time.Duration, bool) {
+	now := time.Now().UnixNano()
+	s := c.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.items[key]
+	if !ok || e.expired(now) {
+		return 0, false
+	}
+	if e.expire > 0 {
+		return time.Duration(e.expire - now), true
+	}
+	return -1, true
+}
+
+// Range 依次遍历各分片, 分片内按最近使用的顺序, 跳过已过期的条目. 每个分片在读锁内复制条目后释放锁再调用 f,
+// f 中可以读写缓存, 遍历到的是复制时的内容
+func (c *ShardedLRUCache[K, V]) Range(f func(key K, value V) (shouldContinue bool)) {
+	now := time.Now().UnixNano()
+	var keys []K
+	var values []V
+	for _, s := range c.shards {
+		keys, values = keys[:0], values[:0]
+		s.mu.RLock()
+		for cursor := s.stack.Front(); cursor != nil; cursor = cursor.Next() {
+			e := cursor.Value.(*entry[K, V])
+			if e.expired(now) {
+				continue
+			}
+			keys = append(keys, e.key)
+			values = append(values, e.value)
+		}
+		s.mu.RUnlock()
+
+		for i := range keys {
+			if !f(keys[i], values[i]) {
+				return
+			}
+		}
+	}
+}
+
+func (c *ShardedLRUCache[K, V]) Contains(key K) bool {
+	_, ok := c.Get(key)
+	return ok
+}
+
+// Set 使用默认存活时间写入
+func (c *ShardedLRUCache[K, V]) Set(key K, value V) bool {
+	return c.SetWithTTL(key, value, c.opts.ttl)
+}
+
+// SetWithTTL 写入并指定存活时间, ttl <= 0 表示不过期. 成本超过总容量时拒绝写入并返回 false, 缓存保持不变
+func (c *ShardedLRUCache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) bool {
+	var expire int64
+	if ttl > 0 {
+		expire = time.Now().Add(ttl).UnixNano()
+	}
+
+	cost := c.cost(key, value)
+	if capacity := atomic.LoadInt64(&c.capacity); capacity > 0 && cost > capacity {
+		c.stats.reject()
+		return false
+	}
+	var pops []popped[K, V]
+	s := c.shard(key)
+	s.mu.Lock()
+	c.stats.set()
+	if e, ok := s.items[key]; ok {
+		s.stack.MoveToFront(e.elem)
+		pops = append(pops, popped[K, V]{key: key, value: e.value, reason: ReasonReplaced})
+		e.value = value
+		e.expire = expire
+		s.charge(cost - e.cost)
+		e.cost = cost
+	} else {
+		e := &entry[K, V]{
+			key:    key,
+			value:  value,
+			expire: expire,
+			cost:   cost,
+		}
+		e.elem = s.stack.PushFront(e)
+		s.items[key] = e
+		s.charge(cost)
+	}
+	pops = s.evictOverflow(pops)
+	s.mu.Unlock()
+	c.emit(pops)
+	if c.overflow() {
+		c.trim(s)
+	}
+	return true
+}
+
+func (c *ShardedLRUCache[K, V]) Remove(key K) bool {
+	var pops []popped[K, V]
+	s := c.shard(key)
+	s.mu.Lock()
+	if e, ok := s.items[key]; ok {
+		pops = s.remove(e, ReasonRemoved, pops)
+	}
+	s.mu.Unlock()
+	c.emit(pops)
+	return len(pops) > 0
+}
+
+// RemoveExpired 移除所有过期条目, 返回移除的数量
+func (c *ShardedLRUCache[K, V]) RemoveExpired() int {
+	now := time.Now().UnixNano()
+	count := 0
+	for _, s := range c.shards {
+		var pops []popped[K, V]
+		s.mu.Lock()
+		for cursor := s.stack.Back(); cursor != nil; {
+			prev := cursor.Prev()
+			if e := cursor.Value.(*entry[K, V]); e.expired(now) {
+				pops = s.remove(e, ReasonExpired, pops)
+			}
+			cursor = prev
+		}
+		s.mu.Unlock()
+		count += len(pops)
+		c.emit(pops)
+	}
+	return count
+}
+
+func (c *ShardedLRUCache[K, V]) startSweeper() {
+	if c.opts.sweep <= 0 || c.opts.wheel == nil {
+		return
+	}
+	c.opts.wheel.AddTimerCustom(c.opts.sweep, c, nil, c.sweep)
+}
+
+func (c *ShardedLRUCache[K, V]) sweep(interface{}) {
+	c.RemoveExpired()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.startSweeper()
+	}
+}
+
+// Close 停止主动清理
+func (c *ShardedLRUCache[K, V]) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	c.closed = true
+	if c.opts.sweep > 0 && c.opts.wheel != nil {
+		c.opts.wheel.RemoveTimer(c)
+	}
+}
+
+func (c *ShardedLRUCache[K, V]) ReCapacity(capacity int) {
+	if capacity < 0 {
+		capacity = -1
+	}
+	atomic.StoreInt64(&c.capacity, int64(capacity))
+
+	for i, s := range c.shards {
+		s.mu.Lock()
+		s.capacity = shardCapacity(capacity, len(c.shards), i)
+		pops := s.evictOverflow(nil)
+		s.mu.Unlock()
+		c.emit(pops)
+	}
+	c.trim(nil)
+}
+
+// Size 条目数量, 包含尚未清理的过期条目
+func (c *ShardedLRUCache[K, V]) Size() int {
+	size := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		size += s.stack.Len()
+		s.mu.RUnlock()
+	}
+	return size
+}
+
+// Cost 各分片条目成本之和, 未设置 WithCost 时与 Size 相同
+func (c *ShardedLRUCache[K, V]) Cost() int64 {
+	return atomic.LoadInt64(&c.used)
+}
+
+func (c *ShardedLRUCache[K, V]) Capacity() int {
+	return int(atomic.LoadInt64(&c.capacity))
+}
+
+func (c *ShardedLRUCache[K, V]) FlushAll() {
+	for _, s := range c.shards {
+		var pops []popped[K, V]
+		s.mu.Lock()
+		for key, e := range s.items {
+			pops = append(pops, popped[K, V]{key: key, value: e.value, reason: ReasonFlushed})
+		}
+		s.items = make(map[K]*entry[K, V])
+		s.charge(-s.used)
+		s.stack.Init()
+		s.mu.Unlock()
+		c.emit(pops)
+	}
+}
+
+// Stats 统计快照, Size 为各分片条目数之和
+func (c *ShardedLRUCache[K, V]) Stats() Stats {
+	s := c.stats.snapshot()
+	s.Size, s.Capacity, s.Cost = c.Size(), c.Capacity(), c.Cost()
+	return s
+}
+
+// ResetStats 清零计数, 不影响缓存的条目
+func (c *ShardedLRUCache[K, V]) ResetStats() {
+	c.stats.reset()
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestShardedLRUCacheCapacity(t *testing.T) {
+	for _, capacity := range []int{1, 10, 100, 1000, 1234} {
+		c := NewShardedLRUCache[int, int](capacity, nil)
+		total := 0
+		for _, s := range c.shards {
+			total += s.capacity
+		}
+		if total != capacity {
+			t.Fatalf("capacity %d: shards hold %d", capacity, total)
+		}
+		for i := 0; i < capacity*4; i++ {
+			c.Set(i, i)
+			if c.Size() > capacity {
+				t.Fatalf("capacity %d: size %d", capacity, c.Size())
+			}
+		}
+	}
+
+	// 容量小于单个分片的最小容量时不分片, 与 LRUCache 的淘汰顺序相同
+	c := NewShardedLRUCache[int, int](10, nil, WithReadSampling(1))
+	if len(c.shards) != 1 {
+		t.Fatalf("shards = %d, want 1", len(c.shards))
+	}
+	for i := 0; i < 10; i++ {
+		c.Set(i, i)
+	}
+	c.Get(0)
+	c.Set(10, 10)
+	if !c.Contains(0) || c.Contains(1) {
+		t.Fatal("evicted the wrong entry")
+	}
+}
+
+func TestShardedLRUCacheRangeWrites(t *testing.T) {
+	c := NewShardedLRUCache[int, int](10000, nil)
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	visited := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// f 中写入所在分片不能死锁
+		c.Range(func(key, value int) bool {
+			if key < 1000 {
+				visited++
+				c.Remove(key)
+				c.Set(key+1000, value)
+			}
+			return true
+		})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Range deadlocked when f wrote to the cache")
+	}
+	if visited != 1000 {
+		t.Fatalf("visited %d entries, want 1000", visited)
+	}
+	for i := 0; i < 1000; i++ {
+		if c.Contains(i) || !c.Contains(i+1000) {
+			t.Fatalf("key %d was not moved", i)
+		}
+	}
+}
+
+func TestShardedLRUCacheCost(t *testing.T) {
+	c := NewShardedLRUCache[int, int](1000, nil, WithCost(func(_ int, cost int) int64 { return int64(cost) }))
+	for i := 0; i < 2000; i++ {
+		c.Set(i, 1)
+	}
+	if c.Cost() != 1000 {
+		t.Fatalf("cost = %d, want 1000", c.Cost())
+	}
+
+	// 超过分片容量但不超过总容量的条目独占分片, 其他分片让出空间
+	if !c.Set(-1, 600) {
+		t.Fatal("rejected an entry within the total capacity")
+	}
+	if value, ok := c.Get(-1); !ok || value != 600 {
+		t.Fatal("lost the large entry")
+	}
+	if c.Cost() > 1000 {
+		t.Fatalf("cost = %d, want <= 1000", c.Cost())
+	}
+	if c.Set(-2, 1001) {
+		t.Fatal("accepted an entry over the total capacity")
+	}
+
+	c.ReCapacity(100)
+	if c.Cost() > 100 {
+		t.Fatalf("cost after ReCapacity = %d, want <= 100", c.Cost())
+	}
+}
+
+const benchCapacity = 1 << 16
+
+func benchmarkParallel(b *testing.B, c Cache[string, int]) {
+	keys := make([]string, benchCapacity*2)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		if i < benchCapacity {
+			c.Set(keys[i], i)
+		}
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			key := keys[r.Intn(len(keys))]
+			// 九成读一成写
+			if r.Intn(10) == 0 {
+				c.Set(key, 0)
+			} else {
+				c.Get(key)
+			}
+		}
+	})
+}
+
+func BenchmarkLRUCacheParallel(b *testing.B) {
+	benchmarkParallel(b, NewLRUCache[string, int](benchCapacity, nil))
+}
+
+func BenchmarkShardedLRUCacheParallel(b *testing.B) {
+	benchmarkParallel(b, NewShardedLRUCache[string, int](benchCapacity, nil))
+}
+
+func BenchmarkShardedLRUCacheParallelSample1(b *testing.B) {
+	benchmarkParallel(b, NewShardedLRUCache[string, int](benchCapacity, nil, WithReadSampling(1)))
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+)
+
+// sketch 4 行的 count-min sketch, 计数上限 15, 累计 10 倍宽度次增加后全部减半以淡化历史频次
+type sketch[K comparable] struct {
+	hasher[K]
+	rows    [4][]uint8
+	mask    uint64
+	added   int
+	resetAt int
+}
+
+func newSketch[K comparable](capacity int) *sketch[K] {
+	if capacity < 16 {
+		capacity = 16
+	}
+	width := 1 << bits.Len(uint(capacity-1))
+	s := &sketch[K]{
+		hasher:  newHasher[K](),
+		mask:    uint64(width - 1),
+		resetAt: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// hasher 任意可比较键的哈希, 常见整数类型与字符串走快速路径
+type hasher[K comparable] struct {
+	seed maphash.Seed
+}
+
+func newHasher[K comparable]() hasher[K] {
+	return hasher[K]{seed: maphash.MakeSeed()}
+}
+
+func (hs hasher[K]) hash(key K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(hs.seed)
+	switch k := any(key).(type) {
+	case string:
+		h.WriteString(k)
+	case int:
+		return mix(uint64(k))
+	case int32:
+		return mix(uint64(k))
+	case int64:
+		return mix(uint64(k))
+	case uint32:
+		return mix(uint64(k))
+	case uint64:
+		return mix(k)
+	default:
+		h.WriteString(fmt.Sprintf("%#v", key))
+	}
+	return h.Sum64()
+}
+
+// mix splitmix64 的混合函数
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (s *sketch[K]) index(h uint64, row int) uint64 {
+	return mix(h+uint64(row)*0x9e3779b97f4a7c15) & s.mask
+}
+
+func (s *sketch[K]) increment(key K) {
+	h := s.hash(key)
+	for i := range s.rows {
+		if idx := s.index(h, i); s.rows[i][idx] < 15 {
+			s.rows[i][idx]++
+		}
+	}
+	if s.added++; s.added >= s.resetAt {
+		s.halve()
+	}
+}
+
+func (s *sketch[K]) estimate(key K) uint8 {
+	h := s.hash(key)
+	least := uint8(15)
+	for i := range s.rows {
+		if c := s.rows[i][s.index(h, i)]; c < least {
+			least = c
+		}
+	}
+	return least
+}
+
+func (s *sketch[K]) halve() {
+	s.added /= 2
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*
+	// 停服前
+	err := players.DumpFile("data/players.snap", utils.GobCodec, "player-v3")
+	// 启动时
+	n, err := players.LoadFile("data/players.snap", utils.GobCodec, "player-v3")
+	if errors.Is(err, utils.ErrSnapshotVersion) {
+		// 数据结构已变化, 冷启动
+	}
+
+快照按保留优先级从高到低(LRU 为最近使用的顺序)写入每个条目及其过期时间, 加载时按相反的顺序写回, LRU 的顺序保持不变.
+其他淘汰策略只恢复条目, 访问频次等统计从零开始. 加载时已过期的条目会被跳过.
+*/
+
+const (
+	snapshotMagic  = "funs_tool/cache.snapshot"
+	snapshotFormat = 1
+)
+
+// ErrSnapshotVersion 快照的版本与期望的不一致
+var ErrSnapshotVersion = errors.New("cache: snapshot version mismatch")
+
+// Codec 快照的编码方式, 需要支持在同一个流中依次编解码多个值
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	return json.NewDecoder(r)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{} // 值中有接口类型时需要先 gob.Register
+)
+
+type snapshotHeader struct {
+	Magic   string
+	Format  int
+	Version string
+	Time    int64
+	Count   int
+}
+
+type snapshotEntry[K comparable, V any] struct {
+	Key    K
+	Value  V
+	Expire int64 // 过期时间(纳秒时间戳), 0 表示不过期
+}
+
+// Dump 将所有未过期的条目写入快照, version 由调用方定义, 加载时不一致则跳过
+func (c *baseCache[K, V]) Dump(w io.Writer, codec Codec, version string) error {
+	var entries []snapshotEntry[K, V]
+	c.lock.Lock()
+	now := time.Now().UnixNano()
+	c.policy.walk(func(e *entry[K, V]) bool {
+		if !e.expired(now) {
+			entries = append(entries, snapshotEntry[K, V]{Key: e.key, Value: e.value, Expire: e.expire})
+		}
+		return true
+	})
+	c.lock.Unlock()
+
+	enc := codec.NewEncoder(w)
+	header := snapshotHeader{
+		Magic:   snapshotMagic,
+		Format:  snapshotFormat,
+		Version: version,
+		Time:    now,
+		Count:   len(entries),
+	}
+	if err := enc.Encode(&header); err != nil {
+		return fmt.Errorf("cache snapshot: %w", err)
+	}
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			return fmt.Errorf("cache snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// Load 读取快照并写入缓存, 返回写入的条目数. 版本不一致时返回 ErrSnapshotVersion, 缓存保持不变
+func (c *baseCache[K, V]) Load(r io.Reader, codec Codec, version string) (int, error) {
+	dec := codec.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("cache snapshot: %w", err)
+	}
+	if header.Magic != snapshotMagic || header.Format != snapshotFormat || header.Count < 0 {
+		return 0, fmt.Errorf("cache snapshot: unknown format %q %d", header.Magic, header.Format)
+	}
+	if header.Version != version {
+		return 0, fmt.Errorf("%w: %q, want %q", ErrSnapshotVersion, header.Version, version)
+	}
+
+	// Count 来自文件, 不按它预分配, 损坏的快照在第一个解码失败的条目处停止
+	var entries []snapshotEntry[K, V]
+	for i := 0; i < header.Count; i++ {
+		var e snapshotEntry[K, V]
+		if err := dec.Decode(&e); err != nil {
+			return 0, fmt.Errorf("cache snapshot: entry %d: %w", i, err)
+		}
+		entries = append(entries, e)
+	}
+
+	count := 0
+	now := time.Now().UnixNano()
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := &entries[i]
+		ttl := time.Duration(0)
+		if e.Expire > 0 {
+			if ttl = time.Duration(e.Expire - now); ttl <= 0 {
+				continue
+			}
+		}
+		if c.SetWithTTL(e.Key, e.Value, ttl) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DumpFile 写入临时文件后替换, 写入失败时不会破坏已有的快照
+func (c *baseCache[K, V]) DumpFile(path string, codec Codec, version string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err = c.Dump(tmp, codec, version); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFile 文件不存在时返回 0, nil
+func (c *baseCache[K, V]) LoadFile(path string, codec Codec, version string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer file.Close()
+	return c.Load(file, codec, version)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func rangeKeys(c Cache[int, string]) []int {
+	var keys []int
+	c.Range(func(key int, _ string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec, GobCodec} {
+		src := NewLRUCache[int, string](10, nil)
+		for i := 1; i <= 4; i++ {
+			src.Set(i, "v")
+		}
+		src.SetWithTTL(5, "ttl", time.Hour)
+		src.Get(2)
+		want := rangeKeys(src)
+
+		var buf bytes.Buffer
+		if err := src.Dump(&buf, codec, "v1"); err != nil {
+			t.Fatal(err)
+		}
+		dst := NewLRUCache[int, string](10, nil)
+		n, err := dst.Load(&buf, codec, "v1")
+		if err != nil || n != 5 {
+			t.Fatalf("Load = %d %v, want 5", n, err)
+		}
+		if got := rangeKeys(dst); !reflect.DeepEqual(got, want) {
+			t.Fatalf("LRU order = %v, want %v", got, want)
+		}
+		if ttl, ok := dst.TTL(5); !ok || ttl <= 0 || ttl > time.Hour {
+			t.Fatalf("TTL = %v %v, want about 1h", ttl, ok)
+		}
+	}
+}
+
+// encodeSnapshot 直接写出快照, 用于构造过期或损坏的内容
+func encodeSnapshot(t *testing.T, header snapshotHeader, entries ...snapshotEntry[int, string]) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := JSONCodec.NewEncoder(&buf)
+	if err := enc.Encode(&header); err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		if err := enc.Encode(&entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buf
+}
+
+func TestSnapshotLoadSkipsExpired(t *testing.T) {
+	now := time.Now().UnixNano()
+	header := snapshotHeader{Magic: snapshotMagic, Format: snapshotFormat, Version: "v1", Count: 3}
+	buf := encodeSnapshot(t, header,
+		snapshotEntry[int, string]{Key: 1, Value: "a"},
+		snapshotEntry[int, string]{Key: 2, Value: "b", Expire: now - int64(time.Second)},
+		snapshotEntry[int, string]{Key: 3, Value: "c", Expire: now + int64(time.Hour)},
+	)
+	c := NewLRUCache[int, string](10, nil)
+	n, err := c.Load(buf, JSONCodec, "v1")
+	if err != nil || n != 2 {
+		t.Fatalf("Load = %d %v, want 2", n, err)
+	}
+	if got := rangeKeys(c); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Fatalf("keys = %v, want [1 3]", got)
+	}
+}
+
+func TestSnapshotLoadErrors(t *testing.T) {
+	src := NewLRUCache[int, string](10, nil)
+	src.Set(1, "a")
+	var buf bytes.Buffer
+	if err := src.Dump(&buf, GobCodec, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	c := NewLRUCache[int, string](10, nil)
+	c.Set(9, "old")
+	if _, err := c.Load(&buf, GobCodec, "v2"); !errors.Is(err, ErrSnapshotVersion) {
+		t.Fatalf("Load = %v, want ErrSnapshotVersion", err)
+	}
+
+	// 条目数远大于实际内容时在第一个解码失败处停止, 缓存保持不变
+	header := snapshotHeader{Magic: snapshotMagic, Format: snapshotFormat, Version: "v1", Count: 1 << 40}
+	corrupt := encodeSnapshot(t, header, snapshotEntry[int, string]{Key: 1, Value: "a"})
+	if n, err := c.Load(corrupt, JSONCodec, "v1"); err == nil || n != 0 {
+		t.Fatalf("Load = %d %v, want an error", n, err)
+	}
+	if got := rangeKeys(c); !reflect.DeepEqual(got, []int{9}) {
+		t.Fatalf("keys = %v, want [9]", got)
+	}
+}
@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/async"
+	"github.com/youngpto/funs_tool/logger"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const reasonCount = int(ReasonFlushed) + 1
+
+// Stats 统计快照, Get/MultipleGet/Contains 计入命中与未命中, Peek 不计入
+type Stats struct {
+	Hits       uint64
+	Misses     uint64
+	Sets       uint64
+	Rejects    uint64              // 成本超过容量被拒绝的写入
+	Evictions  [reasonCount]uint64 // 按 Reason 索引
+	Loads      uint64              // LoadingCache 调用加载函数的次数, 批量加载按一次计
+	LoadErrors uint64
+	LoadTime   time.Duration // 加载总耗时
+	Size       int
+	Capacity   int
+	Cost       int64 // 条目成本之和, 未设置 WithCost 时与 Size 相同
+}
+
+func (s Stats) HitRate() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTime / time.Duration(s.Loads)
+}
+
+func (s Stats) String() string {
+	var sb strings.Builder
+	if s.Cost != int64(s.Size) {
+		fmt.Fprintf(&sb, "size=%d cost=%d/%d", s.Size, s.Cost, s.Capacity)
+	} else {
+		fmt.Fprintf(&sb, "size=%d/%d", s.Size, s.Capacity)
+	}
+	fmt.Fprintf(&sb, " hits=%d misses=%d rate=%.2f%% sets=%d", s.Hits, s.Misses, s.HitRate()*100, s.Sets)
+	if s.Rejects > 0 {
+		fmt.Fprintf(&sb, " rejects=%d", s.Rejects)
+	}
+	for reason, count := range s.Evictions {
+		fmt.Fprintf(&sb, " %s=%d", Reason(reason), count)
+	}
+	if s.Loads > 0 {
+		fmt.Fprintf(&sb, " loads=%d errors=%d avg=%v", s.Loads, s.LoadErrors, s.AvgLoadTime())
+	}
+	return sb.String()
+}
+
+// counters 原子计数器
+type counters struct {
+	hits       uint64
+	misses     uint64
+	sets       uint64
+	rejects    uint64
+	evictions  [reasonCount]uint64
+	loads      uint64
+	loadErrors uint64
+	loadNanos  int64
+}
+
+func (c *counters) hit(n int) {
+	atomic.AddUint64(&c.hits, uint64(n))
+}
+
+func (c *counters) miss(n int) {
+	atomic.AddUint64(&c.misses, uint64(n))
+}
+
+func (c *counters) set() {
+	atomic.AddUint64(&c.sets, 1)
+}
+
+func (c *counters) reject() {
+	atomic.AddUint64(&c.rejects, 1)
+}
+
+func (c *counters) evict(reason Reason, n int) {
+	atomic.AddUint64(&c.evictions[reason], uint64(n))
+}
+
+func (c *counters) load(cost time.Duration, err error) {
+	atomic.AddUint64(&c.loads, 1)
+	atomic.AddInt64(&c.loadNanos, int64(cost))
+	if err != nil {
+		atomic.AddUint64(&c.loadErrors, 1)
+	}
+}
+
+func (c *counters) snapshot() Stats {
+	s := Stats{
+		Hits:       atomic.LoadUint64(&c.hits),
+		Misses:     atomic.LoadUint64(&c.misses),
+		Sets:       atomic.LoadUint64(&c.sets),
+		Rejects:    atomic.LoadUint64(&c.rejects),
+		Loads:      atomic.LoadUint64(&c.loads),
+		LoadErrors: atomic.LoadUint64(&c.loadErrors),
+		LoadTime:   time.Duration(atomic.LoadInt64(&c.loadNanos)),
+	}
+	for i := range c.evictions {
+		s.Evictions[i] = atomic.LoadUint64(&c.evictions[i])
+	}
+	return s
+}
+
+func (c *counters) reset() {
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.misses, 0)
+	atomic.StoreUint64(&c.sets, 0)
+	atomic.StoreUint64(&c.rejects, 0)
+	atomic.StoreUint64(&c.loads, 0)
+	atomic.StoreUint64(&c.loadErrors, 0)
+	atomic.StoreInt64(&c.loadNanos, 0)
+	for i := range c.evictions {
+		atomic.StoreUint64(&c.evictions[i], 0)
+	}
+}
+
+// StatsProvider 可以输出统计的缓存
+type StatsProvider interface {
+	Stats() Stats
+	ResetStats()
+}
+
+// Report 每隔 interval 通过 logger 输出一次统计, reset 为 true 时输出后清零, 即每次输出的是区间内的数据.
+// 返回的函数用于停止输出
+func Report(name string, cache StatsProvider, interval time.Duration, reset bool) (stop func()) {
+	stopChan := make(chan struct{})
+	async.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopChan:
+				return
+			case <-ticker.C:
+				logger.Info("cache %s: %v", name, cache.Stats())
+				if reset {
+					cache.ResetStats()
+				}
+			}
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stopChan)
+		})
+	}
+}
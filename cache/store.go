@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"github.com/youngpto/funs_tool/async"
+	"github.com/youngpto/funs_tool/logger"
+	"sync"
+	"time"
+)
+
+/*
+	players := utils.NewStoreCache[int64, *Player](
+		utils.NewLRUCache[int64, *Player](5000, nil),
+		playerStore,
+		utils.WithWriteBehind(5*time.Second),
+		utils.WithFlushBatch(200),
+	)
+	defer players.Close()
+
+	player, err := players.Get(ctx, uid)
+	player.Level++
+	err = players.Set(ctx, uid, player)
+
+同步写入(默认)时 Set/Delete 先写存储, 成功后再更新缓存. 异步写入时只更新缓存并记为脏数据,
+同一个键的多次写入合并为一次, 由后台按批写入, 失败的批次重试后留到下一轮. 脏数据在写入存储前即使被缓存淘汰也不会丢失,
+Get 会优先读取尚未写入的值. Close 会写完所有脏数据.
+*/
+
+// ErrClosed 在 StoreCache 关闭后写入
+var ErrClosed = errors.New("cache: closed")
+
+// Store 缓存背后的持久化存储
+type Store[K comparable, V any] interface {
+	Load(ctx context.Context, key K) (V, error) // 键不存在时返回 ErrNotFound
+	Save(ctx context.Context, values map[K]V) error
+	Delete(ctx context.Context, keys []K) error
+}
+
+// StoreCache 读取时从存储加载, 写入时同步或异步写回存储
+type StoreCache[K comparable, V any] struct {
+	cache  *LoadingCache[K, V]
+	store  Store[K, V]
+	opts   *options
+	mu     sync.Mutex
+	dirty  map[K]*dirtyEntry[V]
+	seq    uint64
+	closed bool
+
+	flushMu sync.Mutex // 同一时间只有一个批量写入
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+type dirtyEntry[V any] struct {
+	value   V
+	deleted bool
+	seq     uint64 // 写入序号, 写回存储后序号未变才清除
+}
+
+func NewStoreCache[K comparable, V any](cache Cache[K, V], store Store[K, V], opts ...Option) *StoreCache[K, V] {
+	sc := &StoreCache[K, V]{
+		cache: NewLoadingCache[K, V](cache, opts...),
+		store: store,
+		opts:  newOptions(opts),
+		dirty: make(map[K]*dirtyEntry[V]),
+	}
+	if sc.opts.flush > 0 {
+		sc.kick = make(chan struct{}, 1)
+		sc.stop = make(chan struct{})
+		sc.done = make(chan struct{})
+		async.Go(sc.flushLoop)
+	}
+	return sc
+}
+
+// Get 依次读取缓存、未写入的脏数据和存储, 并发的加载会合并为一次
+func (sc *StoreCache[K, V]) Get(ctx context.Context, key K) (V, error) {
+	return sc.cache.GetOrLoad(ctx, key, sc.load)
+}
+
+func (sc *StoreCache[K, V]) load(ctx context.Context, key K) (V, error) {
+	sc.mu.Lock()
+	d, ok := sc.dirty[key]
+	sc.mu.Unlock()
+	if !ok {
+		return sc.store.Load(ctx, key)
+	}
+	if d.deleted {
+		var zero V
+		return zero, ErrNotFound
+	}
+	return d.value, nil
+}
+
+// Set 写入缓存与存储, 同步写入时存储失败则缓存不变. 关闭后返回 ErrClosed
+func (sc *StoreCache[K, V]) Set(ctx context.Context, key K, value V) error {
+	if sc.isClosed() {
+		return ErrClosed
+	}
+	if sc.opts.flush <= 0 {
+		if err := sc.store.Save(ctx, map[K]V{key: value}); err != nil {
+			return err
+		}
+		sc.cache.Set(key, value)
+		return nil
+	}
+
+	if err := sc.markDirty(key, &dirtyEntry[V]{value: value}); err != nil {
+		return err
+	}
+	sc.cache.Set(key, value)
+	return nil
+}
+
+// Delete 从缓存与存储中删除, 关闭后返回 ErrClosed
+func (sc *StoreCache[K, V]) Delete(ctx context.Context, key K) error {
+	if sc.isClosed() {
+		return ErrClosed
+	}
+	if sc.opts.flush <= 0 {
+		if err := sc.store.Delete(ctx, []K{key}); err != nil {
+			return err
+		}
+		sc.cache.Remove(key)
+		return nil
+	}
+
+	if err := sc.markDirty(key, &dirtyEntry[V]{deleted: true}); err != nil {
+		return err
+	}
+	sc.cache.Remove(key)
+	return nil
+}
+
+func (sc *StoreCache[K, V]) isClosed() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.closed
+}
+
+// markDirty 在锁内再次检查 closed, 避免与 Close 并发时在最后一次写入后记下脏数据
+func (sc *StoreCache[K, V]) markDirty(key K, d *dirtyEntry[V]) error {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return ErrClosed
+	}
+	sc.seq++
+	d.seq = sc.seq
+	sc.dirty[key] = d
+	full := len(sc.dirty) >= sc.opts.batch
+	sc.mu.Unlock()
+
+	if full {
+		select {
+		case sc.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Pending 尚未写入存储的键的数量
+func (sc *StoreCache[K, V]) Pending() int {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return len(sc.dirty)
+}
+
+// Cache 底层的缓存, 直接写入不会同步到存储
+func (sc *StoreCache[K, V]) Cache() Cache[K, V] {
+	return sc.cache.Cache
+}
+
+func (sc *StoreCache[K, V]) Stats() Stats {
+	return sc.cache.Stats()
+}
+
+func (sc *StoreCache[K, V]) ResetStats() {
+	sc.cache.ResetStats()
+}
+
+func (sc *StoreCache[K, V]) flushLoop() {
+	defer close(sc.done)
+	ticker := time.NewTicker(sc.opts.flush)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sc.stop:
+			return
+		case <-ticker.C:
+		case <-sc.kick:
+		}
+		if err := sc.Flush(context.Background()); err != nil {
+			logger.Error("cache store flush failed, %d pending: %v", sc.Pending(), err)
+		}
+	}
+}
+
+// Flush 立即按批写入当前所有脏数据, 返回第一个重试后仍失败的错误, 失败的批次留到下一轮
+func (sc *StoreCache[K, V]) Flush(ctx context.Context) error {
+	sc.flushMu.Lock()
+	defer sc.flushMu.Unlock()
+
+	sc.mu.Lock()
+	keys := make([]K, 0, len(sc.dirty))
+	for key := range sc.dirty {
+		keys = append(keys, key)
+	}
+	sc.mu.Unlock()
+
+	var firstErr error
+	for start := 0; start < len(keys); start += sc.opts.batch {
+		end := start + sc.opts.batch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := sc.flushBatch(ctx, keys[start:end]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return firstErr
+}
+
+func (sc *StoreCache[K, V]) flushBatch(ctx context.Context, keys []K) error {
+	saves := make(map[K]V, len(keys))
+	var deletes []K
+	seqs := make(map[K]uint64, len(keys))
+	sc.mu.Lock()
+	for _, key := range keys {
+		d, ok := sc.dirty[key]
+		if !ok {
+			continue
+		}
+		seqs[key] = d.seq
+		if d.deleted {
+			deletes = append(deletes, key)
+		} else {
+			saves[key] = d.value
+		}
+	}
+	sc.mu.Unlock()
+
+	err := sc.retry(ctx, func() error {
+		if len(saves) > 0 {
+			if err := sc.store.Save(ctx, saves); err != nil {
+				return err
+			}
+			// 重试时不再重复写入已成功的部分
+			saves = nil
+		}
+		if len(deletes) > 0 {
+			return sc.store.Delete(ctx, deletes)
+		}
+		return nil
+	})
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for key, seq := range seqs {
+		d, ok := sc.dirty[key]
+		if !ok || d.seq != seq {
+			continue
+		}
+		// 写入失败的条目留到下一轮
+		if _, failed := saves[key]; failed || (err != nil && d.deleted) {
+			continue
+		}
+		delete(sc.dirty, key)
+	}
+	return err
+}
+
+// retry 失败后按翻倍的间隔重试, ctx 结束时停止
+func (sc *StoreCache[K, V]) retry(ctx context.Context, f func() error) (err error) {
+	delay := sc.opts.retryDelay
+	for i := 0; ; i++ {
+		if err = f(); err == nil || i >= sc.opts.retries {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// Close 停止后台写入并写完所有脏数据, 之后的 Set/Delete 返回 ErrClosed. 不会关闭底层缓存
+func (sc *StoreCache[K, V]) Close() error {
+	sc.mu.Lock()
+	if sc.closed {
+		sc.mu.Unlock()
+		return nil
+	}
+	sc.closed = true
+	sc.mu.Unlock()
+
+	if sc.opts.flush <= 0 {
+		return nil
+	}
+	close(sc.stop)
+	<-sc.done
+	return sc.Flush(context.Background())
+}
+
+// MemoryStore 内存中的 Store, 用于测试或不需要持久化的场景
+type MemoryStore[K comparable, V any] struct {
+	mu     sync.RWMutex
+	values map[K]V
+}
+
+func NewMemoryStore[K comparable, V any]() *MemoryStore[K, V] {
+	return &MemoryStore[K, V]{values: make(map[K]V)}
+}
+
+func (s *MemoryStore[K, V]) Load(_ context.Context, key K) (V, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	value, ok := s.values[key]
+	if !ok {
+		return value, ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *MemoryStore[K, V]) Save(_ context.Context, values map[K]V) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, value := range values {
+		s.values[key] = value
+	}
+	return nil
+}
+
+func (s *MemoryStore[K, V]) Delete(_ context.Context, keys []K) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.values)
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errStoreDown = errors.New("store down")
+
+// testStore 在 MemoryStore 上记录写入并可以模拟失败
+type testStore struct {
+	*MemoryStore[int, string]
+	mu      sync.Mutex
+	fail    bool
+	saves   []map[int]string
+	loads   int
+	loading chan struct{} // 非 nil 时 Load 读取后等待其关闭再返回
+}
+
+func newTestStore() *testStore {
+	return &testStore{MemoryStore: NewMemoryStore[int, string]()}
+}
+
+func (s *testStore) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *testStore) Load(ctx context.Context, key int) (string, error) {
+	s.mu.Lock()
+	s.loads++
+	loading := s.loading
+	s.mu.Unlock()
+	value, err := s.MemoryStore.Load(ctx, key)
+	if loading != nil {
+		<-loading
+	}
+	return value, err
+}
+
+func (s *testStore) Save(ctx context.Context, values map[int]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errStoreDown
+	}
+	saved := make(map[int]string, len(values))
+	for key, value := range values {
+		saved[key] = value
+	}
+	s.saves = append(s.saves, saved)
+	return s.MemoryStore.Save(ctx, values)
+}
+
+func (s *testStore) Delete(ctx context.Context, keys []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errStoreDown
+	}
+	return s.MemoryStore.Delete(ctx, keys)
+}
+
+func TestStoreCacheWriteThroughFailure(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store)
+	if err := sc.Set(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	store.setFail(true)
+	if err := sc.Set(ctx, 1, "b"); !errors.Is(err, errStoreDown) {
+		t.Fatalf("Set error = %v, want %v", err, errStoreDown)
+	}
+	if value, ok := sc.Cache().Get(1); !ok || value != "a" {
+		t.Fatalf("cache = %q %v, want a", value, ok)
+	}
+	if err := sc.Delete(ctx, 1); !errors.Is(err, errStoreDown) {
+		t.Fatalf("Delete error = %v, want %v", err, errStoreDown)
+	}
+	if !sc.Cache().Contains(1) {
+		t.Fatal("failed Delete removed the cached value")
+	}
+}
+
+func TestStoreCacheWriteBehindCoalesce(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store, WithWriteBehind(time.Hour))
+	defer sc.Close()
+
+	for _, value := range []string{"a", "b", "c"} {
+		if err := sc.Set(ctx, 1, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := sc.Pending(); n != 1 {
+		t.Fatalf("Pending = %d, want 1", n)
+	}
+	if err := sc.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.saves) != 1 || len(store.saves[0]) != 1 || store.saves[0][1] != "c" {
+		t.Fatalf("saves = %v, want one save of 1=c", store.saves)
+	}
+}
+
+func TestStoreCacheServesEvictedDirty(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](1, nil), store, WithWriteBehind(time.Hour))
+	defer sc.Close()
+
+	sc.Set(ctx, 1, "a")
+	sc.Set(ctx, 2, "b")
+	if sc.Cache().Contains(1) {
+		t.Fatal("key 1 should have been evicted")
+	}
+	value, err := sc.Get(ctx, 1)
+	if err != nil || value != "a" {
+		t.Fatalf("Get = %q %v, want a", value, err)
+	}
+	if store.loads != 0 {
+		t.Fatalf("store loaded %d times, want 0", store.loads)
+	}
+
+	sc.Delete(ctx, 2)
+	if _, err := sc.Get(ctx, 2); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get deleted = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreCacheFailedBatchStaysPending(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store,
+		WithWriteBehind(time.Hour), WithRetry(1, time.Millisecond))
+	defer sc.Close()
+
+	store.setFail(true)
+	sc.Set(ctx, 1, "a")
+	sc.Set(ctx, 2, "b")
+	if err := sc.Flush(ctx); !errors.Is(err, errStoreDown) {
+		t.Fatalf("Flush error = %v, want %v", err, errStoreDown)
+	}
+	if n := sc.Pending(); n != 2 {
+		t.Fatalf("Pending = %d, want 2", n)
+	}
+
+	store.setFail(false)
+	if err := sc.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := sc.Pending(); n != 0 {
+		t.Fatalf("Pending = %d, want 0", n)
+	}
+	if store.Len() != 2 {
+		t.Fatalf("store has %d values, want 2", store.Len())
+	}
+}
+
+func TestStoreCacheCloseDrains(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store,
+		WithWriteBehind(time.Hour), WithFlushBatch(2))
+
+	for i := 0; i < 5; i++ {
+		sc.Set(ctx, i, "v")
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := sc.Pending(); n != 0 {
+		t.Fatalf("Pending = %d, want 0", n)
+	}
+	if store.Len() != 5 {
+		t.Fatalf("store has %d values, want 5", store.Len())
+	}
+	if err := sc.Set(ctx, 9, "v"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Set after Close = %v, want ErrClosed", err)
+	}
+	if err := sc.Delete(ctx, 0); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Delete after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestStoreCacheWriteThroughClosed(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store)
+	if err := sc.Set(ctx, 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sc.Set(ctx, 1, "b"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Set after Close = %v, want ErrClosed", err)
+	}
+	if err := sc.Delete(ctx, 1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Delete after Close = %v, want ErrClosed", err)
+	}
+	if len(store.saves) != 1 || store.Len() != 1 {
+		t.Fatalf("store written after Close: saves = %v", store.saves)
+	}
+	if value, err := sc.Get(ctx, 1); err != nil || value != "a" {
+		t.Fatalf("Get = %q %v, want a", value, err)
+	}
+}
+
+func TestStoreCacheSetDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore()
+	store.MemoryStore.Save(ctx, map[int]string{1: "old"})
+	store.loading = make(chan struct{})
+	sc := NewStoreCache[int, string](NewLRUCache[int, string](10, nil), store)
+
+	loaded := make(chan string)
+	go func() {
+		value, _ := sc.Get(ctx, 1)
+		loaded <- value
+	}()
+	for {
+		store.mu.Lock()
+		loads := store.loads
+		store.mu.Unlock()
+		if loads > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 加载开始之后写入新值, 加载读到的旧值不能覆盖它
+	if err := sc.Set(ctx, 1, "new"); err != nil {
+		t.Fatal(err)
+	}
+	close(store.loading)
+	if value := <-loaded; value != "old" {
+		t.Fatalf("loaded = %q, want old", value)
+	}
+
+	if value, ok := sc.Cache().Get(1); !ok || value != "new" {
+		t.Fatalf("cache = %q %v, want new", value, ok)
+	}
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"container/list"
+	"github.com/youngpto/funs_tool/math_utils"
+)
+
+// TinyLFUCache W-TinyLFU: 新条目先进入占容量 1% 的 LRU 窗口, 离开窗口时与主区(SLRU)的淘汰候选比较
+// count-min sketch 估计的访问频次, 频次更高的一方留下. 适合扫描较多的场景. 设置 WithCost 时各区域的大小按成本计算,
+// 频次统计的宽度随条目数增长
+type TinyLFUCache[K comparable, V any] struct {
+	baseCache[K, V]
+}
+
+func NewTinyLFUCache[K comparable, V any](capacity int, popCb PopCallback[K, V], opts ...Option) *TinyLFUCache[K, V] {
+	tiny := &TinyLFUCache[K, V]{
+		baseCache: newBaseCache[K, V](capacity, nil, popCb, opts),
+	}
+	tiny.policy = newTinyLFUPolicy[K, V](capacity, tiny.opts.cost != nil)
+	tiny.startSweeper()
+	return tiny
+}
+
+const (
+	tinyWindow = iota
+	tinyProbation
+	tinyProtected
+)
+
+type tinyLFUPolicy[K comparable, V any] struct {
+	windowCap    int64
+	mainCap      int64
+	protectedCap int64
+	segs         [3]*list.List // 窗口、试用区、保护区, 表头为最近使用
+	size         [3]int64      // 各区域的成本
+	sketch       *sketch[K]
+	weighted     bool // 容量按成本计算, sketch 按条目数扩容
+}
+
+func newTinyLFUPolicy[K comparable, V any](capacity int, weighted bool) *tinyLFUPolicy[K, V] {
+	p := &tinyLFUPolicy[K, V]{weighted: weighted}
+	for i := range p.segs {
+		p.segs[i] = list.New()
+	}
+	p.resize(capacity)
+	return p
+}
+
+func (p *tinyLFUPolicy[K, V]) push(e *entry[K, V], seg int) {
+	e.seg = seg
+	e.elem = p.segs[seg].PushFront(e)
+	p.size[seg] += e.cost
+}
+
+func (p *tinyLFUPolicy[K, V]) move(e *entry[K, V], seg int) {
+	p.remove(e)
+	p.push(e, seg)
+}
+
+func (p *tinyLFUPolicy[K, V]) add(e *entry[K, V]) {
+	if n := p.len(); p.weighted && uint64(n) > p.sketch.mask {
+		p.sketch = newSketch[K](n * 2)
+	}
+	p.sketch.increment(e.key)
+	p.push(e, tinyWindow)
+}
+
+func (p *tinyLFUPolicy[K, V]) len() int {
+	return p.segs[tinyWindow].Len() + p.segs[tinyProbation].Len() + p.segs[tinyProtected].Len()
+}
+
+func (p *tinyLFUPolicy[K, V]) hit(e *entry[K, V]) {
+	p.sketch.increment(e.key)
+	switch e.seg {
+	case tinyWindow, tinyProtected:
+		p.segs[e.seg].MoveToFront(e.elem)
+	case tinyProbation:
+		p.move(e, tinyProtected)
+		p.demote(e)
+	}
+}
+
+// demote 保护区超出容量时把最久未使用的条目降回试用区, 刚提升的 keep 除外
+func (p *tinyLFUPolicy[K, V]) demote(keep *entry[K, V]) {
+	for protected := p.segs[tinyProtected]; p.size[tinyProtected] > p.protectedCap; {
+		back := protected.Back().Value.(*entry[K, V])
+		if back == keep {
+			return
+		}
+		p.move(back, tinyProbation)
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) remove(e *entry[K, V]) {
+	p.segs[e.seg].Remove(e.elem)
+	p.size[e.seg] -= e.cost
+}
+
+func (p *tinyLFUPolicy[K, V]) recost(e *entry[K, V], old int64) {
+	p.size[e.seg] += e.cost - old
+	if e.seg == tinyProtected {
+		p.demote(e)
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) mainSize() int64 {
+	return p.size[tinyProbation] + p.size[tinyProtected]
+}
+
+// victim 主区的淘汰候选, 优先取试用区
+func (p *tinyLFUPolicy[K, V]) victim() *entry[K, V] {
+	for _, seg := range []int{tinyProbation, tinyProtected} {
+		if back := p.segs[seg].Back(); back != nil {
+			return back.Value.(*entry[K, V])
+		}
+	}
+	return nil
+}
+
+func (p *tinyLFUPolicy[K, V]) evict() *entry[K, V] {
+	window := p.segs[tinyWindow]
+	for {
+		var candidate *entry[K, V]
+		if p.size[tinyWindow] > p.windowCap {
+			candidate = window.Back().Value.(*entry[K, V])
+			p.move(candidate, tinyProbation)
+		}
+		if p.mainSize() <= p.mainCap {
+			if candidate != nil {
+				continue
+			}
+			// 主区未满而窗口也未超出, 只可能是容量为 0
+			if back := window.Back(); back != nil {
+				return p.drop(back.Value.(*entry[K, V]))
+			}
+			return nil
+		}
+
+		victim := p.victim()
+		if candidate == nil || candidate == victim {
+			return p.drop(victim)
+		}
+		if p.sketch.estimate(candidate.key) > p.sketch.estimate(victim.key) {
+			return p.drop(victim)
+		}
+		return p.drop(candidate)
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) drop(e *entry[K, V]) *entry[K, V] {
+	if e != nil {
+		p.remove(e)
+	}
+	return e
+}
+
+func (p *tinyLFUPolicy[K, V]) walk(f func(e *entry[K, V]) bool) {
+	for _, seg := range []int{tinyProtected, tinyWindow, tinyProbation} {
+		for cursor := p.segs[seg].Front(); cursor != nil; cursor = cursor.Next() {
+			if !f(cursor.Value.(*entry[K, V])) {
+				return
+			}
+		}
+	}
+}
+
+func (p *tinyLFUPolicy[K, V]) resize(capacity int) {
+	if capacity < 0 {
+		// 不限容量时全部留在窗口, 不会触发淘汰
+		p.windowCap, p.mainCap, p.protectedCap = -1, 0, 0
+		p.sketch = newSketch[K](0)
+		return
+	}
+	p.windowCap = math_utils.Max(int64(capacity)/100, 1)
+	p.mainCap = math_utils.Max(int64(capacity)-p.windowCap, 0)
+	p.protectedCap = p.mainCap * 8 / 10
+	p.sketch = newSketch[K](p.sketchWidth())
+	p.demote(nil)
+}
+
+// sketchWidth 按成本计算时容量不代表条目数, 从当前条目数开始
+func (p *tinyLFUPolicy[K, V]) sketchWidth() int {
+	if p.weighted {
+		return p.len()
+	}
+	return int(p.mainCap + p.windowCap)
+}
+
+func (p *tinyLFUPolicy[K, V]) reset() {
+	for i, l := range p.segs {
+		l.Init()
+		p.size[i] = 0
+	}
+	p.sketch = newSketch[K](p.sketchWidth())
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/youngpto/funs_tool/datapack"
+	"github.com/youngpto/funs_tool/datapack/bundle"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+/*
+	go run github.com/youngpto/funs_tool/cmd/conf2src -root ./bin/game_conf -out ./conf/generated.go -blob ./conf/msgpack.json
+	go run github.com/youngpto/funs_tool/cmd/conf2src -check   // 生成结果过期时以非零状态退出, 适合 CI
+	go run github.com/youngpto/funs_tool/cmd/conf2src -dry-run // 只列出发现的表与推断的类型
+	go run github.com/youngpto/funs_tool/cmd/conf2src -i18n ./conf/lang -base-lang cn -langs en,ja
+*/
+
+var (
+	root     = flag.String("root", "./bin/game_conf/", "config root directory")
+	out      = flag.String("out", "./conf/generated.go", "generated go file")
+	blob     = flag.String("blob", "./conf/msgpack.json", "packed config file")
+	pkg      = flag.String("package", "conf", "package name of generated code")
+	formats  = flag.String("formats", "", "comma separated file extensions to process, empty for all registered")
+	verbose  = flag.Int("v", 1, "verbosity: 0 quiet, 1 summary, 2 every visited file")
+	check    = flag.Bool("check", false, "exit non-zero if generated files are out of date, write nothing")
+	dryRun   = flag.Bool("dry-run", false, "list discovered tables and inferred types, write nothing")
+	bundled  = flag.String("bundle", "", "write a bundle with compression none, flate or gzip; empty for plain json")
+	revision = flag.String("revision", "", "source revision recorded in the bundle")
+	embed    = flag.Bool("embed", false, "embed the packed file into the generated package with go:embed")
+	i18nDir  = flag.String("i18n", "", "extract :text columns into string tables under this directory")
+	baseLang = flag.String("base-lang", "cn", "language of the inline config text")
+	langs    = flag.String("langs", "", "comma separated languages to keep string tables for besides base-lang")
+)
+
+func main() {
+	flag.Parse()
+
+	opts := []datapack.Option{
+		datapack.WithPackage(*pkg),
+		datapack.WithVerbose(*verbose),
+	}
+	if *check || *dryRun {
+		opts[1] = datapack.WithVerbose(0)
+	}
+	if *formats != "" {
+		opts = append(opts, datapack.WithFormats(strings.Split(*formats, ",")...))
+	}
+	if *bundled != "" {
+		compression, ok := map[string]bundle.Compression{
+			"none":  bundle.NoCompression,
+			"flate": bundle.Flate,
+			"gzip":  bundle.Gzip,
+		}[*bundled]
+		if !ok {
+			fatal("unknown bundle compression %q", *bundled)
+		}
+		opts = append(opts, datapack.WithBundle(compression), datapack.WithRevision(*revision))
+	}
+	if *embed {
+		opts = append(opts, datapack.WithEmbed())
+	}
+	if *i18nDir != "" {
+		var locales []string
+		if *langs != "" {
+			locales = strings.Split(*langs, ",")
+		}
+		opts = append(opts, datapack.WithLocalize(*i18nDir, *baseLang, locales...))
+	}
+
+	switch {
+	case *dryRun:
+		output, err := datapack.Generate(*root, *out, *blob, opts...)
+		if err != nil {
+			fatal("%v", err)
+		}
+		printTables(output.Tables)
+	case *check:
+		output, err := datapack.Generate(*root, *out, *blob, opts...)
+		if err != nil {
+			fatal("%v", err)
+		}
+		if stale := output.Stale(*out, *blob); len(stale) > 0 {
+			fatal("out of date: %s", strings.Join(stale, ", "))
+		}
+	default:
+		if err := datapack.Conf2Src(*root, *out, *blob, opts...); err != nil {
+			fatal("%v", err)
+		}
+	}
+}
+
+var layouts = map[datapack.Layout]string{
+	datapack.RowsLayout:   "rows",
+	datapack.ObjectLayout: "object",
+	datapack.ArrayLayout:  "array",
+}
+
+func printTables(tables []datapack.TableInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer w.Flush()
+	for _, table := range tables {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", table.Path, layouts[table.Table.Layout], table.Struct, table.Type)
+		for _, field := range table.Table.Fields {
+			typ := summarizeType(field.Type)
+			if field.Text {
+				typ += " (text)"
+			}
+			fmt.Fprintf(w, "\t\t%s\t%s\n", field.Name, typ)
+		}
+	}
+}
+
+// summarizeType 嵌套结构的类型带有换行与注释, 只显示字段数以免破坏表格
+func summarizeType(typ string) string {
+	start := strings.Index(typ, "struct {")
+	if start < 0 {
+		return strings.Join(strings.Fields(typ), " ")
+	}
+	fields, depth := 0, 0
+	for _, line := range strings.Split(typ[start:], "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "//") {
+			continue
+		}
+		if depth == 1 && line != "" && !strings.HasPrefix(line, "}") {
+			fields++
+		}
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+	}
+	return fmt.Sprintf("%sstruct{…} (%d fields)", typ[:start], fields)
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "conf2src: "+format+"\n", args...)
+	os.Exit(1)
+}
@@ -0,0 +1,207 @@
+package bundle
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+)
+
+/*
+* 打包文件格式, 整数均为大端序
+*
+* +--------+---------+-------------+--------+------------+---------+----------+------------+---------+----------+
+* | magic  | version | compression | schema | build time | rev len | revision | payload len| payload | checksum |
+* | 4      | 2       | 2           | 32     | 8          | 2       | n        | 8          | n       | 4        |
+* +--------+---------+-------------+--------+------------+---------+----------+------------+---------+----------+
+*
+* schema 为生成代码的结构哈希(sha256), build time 为纳秒时间戳, checksum 为之前全部字节的 crc32(Castagnoli)
+ */
+
+const Version uint16 = 1
+
+var Magic = [4]byte{'F', 'S', 'C', 'B'}
+
+type Compression uint16
+
+const (
+	NoCompression Compression = iota
+	Flate
+	Gzip
+)
+
+var (
+	ErrMagic     = errors.New("bundle: invalid magic")
+	ErrVersion   = errors.New("bundle: unsupported version")
+	ErrTruncated = errors.New("bundle: truncated data")
+	ErrChecksum  = errors.New("bundle: checksum mismatch")
+	ErrSchema    = errors.New("bundle: schema mismatch")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Header 打包文件头
+type Header struct {
+	Version     uint16
+	Compression Compression
+	Schema      [32]byte
+	BuildTime   time.Time
+	Revision    string
+}
+
+// IsBundle 数据是否以打包文件魔数开头
+func IsBundle(data []byte) bool {
+	return len(data) >= len(Magic) && bytes.Equal(data[:len(Magic)], Magic[:])
+}
+
+// Encode 压缩并封装数据
+func Encode(h Header, payload []byte) ([]byte, error) {
+	if len(h.Revision) > 0xFFFF {
+		return nil, fmt.Errorf("bundle: revision too long")
+	}
+	compressed, err := compress(h.Compression, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(Magic[:])
+	_ = binary.Write(&buf, binary.BigEndian, Version)
+	_ = binary.Write(&buf, binary.BigEndian, uint16(h.Compression))
+	buf.Write(h.Schema[:])
+	_ = binary.Write(&buf, binary.BigEndian, h.BuildTime.UnixNano())
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(h.Revision)))
+	buf.WriteString(h.Revision)
+	_ = binary.Write(&buf, binary.BigEndian, uint64(len(compressed)))
+	buf.Write(compressed)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.Checksum(buf.Bytes(), crcTable))
+	return buf.Bytes(), nil
+}
+
+// Decode 校验并解出数据
+func Decode(data []byte) (Header, []byte, error) {
+	var h Header
+	if !IsBundle(data) {
+		return h, nil, ErrMagic
+	}
+	if len(data) < len(Magic)+4 {
+		return h, nil, ErrTruncated
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+
+	r := bytes.NewReader(body[len(Magic):])
+	var (
+		compression uint16
+		build       int64
+		revLen      uint16
+		payloadLen  uint64
+	)
+	if err := binary.Read(r, binary.BigEndian, &h.Version); err != nil {
+		return h, nil, ErrTruncated
+	}
+	if h.Version != Version {
+		return h, nil, ErrVersion
+	}
+	if err := readAll(r, &compression, &h.Schema, &build, &revLen); err != nil {
+		return h, nil, err
+	}
+	rev := make([]byte, revLen)
+	if _, err := io.ReadFull(r, rev); err != nil {
+		return h, nil, ErrTruncated
+	}
+	if err := readAll(r, &payloadLen); err != nil {
+		return h, nil, err
+	}
+	if uint64(r.Len()) != payloadLen {
+		return h, nil, ErrTruncated
+	}
+	if crc32.Checksum(body, crcTable) != sum {
+		return h, nil, ErrChecksum
+	}
+
+	h.Compression = Compression(compression)
+	h.BuildTime = time.Unix(0, build)
+	h.Revision = string(rev)
+	payload, err := decompress(h.Compression, body[len(body)-int(payloadLen):])
+	if err != nil {
+		return h, nil, err
+	}
+	return h, payload, nil
+}
+
+// Open 校验结构哈希(十六进制)后解出数据, 用于生成代码的加载
+func Open(data []byte, schema string) ([]byte, error) {
+	h, payload, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if hex.EncodeToString(h.Schema[:]) != schema {
+		return nil, ErrSchema
+	}
+	return payload, nil
+}
+
+func readAll(r io.Reader, values ...interface{}) error {
+	for _, v := range values {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return ErrTruncated
+		}
+	}
+	return nil
+}
+
+func compress(c Compression, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch c {
+	case NoCompression:
+		return payload, nil
+	case Flate:
+		fw, err := flate.NewWriter(&buf, flate.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = fw
+	case Gzip:
+		gw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		w = gw
+	default:
+		return nil, fmt.Errorf("bundle: unknown compression %d", c)
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(c Compression, payload []byte) ([]byte, error) {
+	var r io.ReadCloser
+	switch c {
+	case NoCompression:
+		return payload, nil
+	case Flate:
+		r = flate.NewReader(bytes.NewReader(payload))
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		r = gr
+	default:
+		return nil, fmt.Errorf("bundle: unknown compression %d", c)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"encoding/csv"
 	"fmt"
+	"github.com/youngpto/funs_tool/datapack/format"
 	"github.com/youngpto/funs_tool/string_utils"
+	"github.com/youngpto/funs_tool/times"
 	"os"
 	"strconv"
 	"strings"
@@ -18,9 +20,19 @@ const (
 	StringType
 	ArrayType
 	MapType
+	TimeType
+	DurationType
 )
 
-var GoTypes = []string{"interface{}", "bool", "int", "float64", "string", "fs_csv.Slice", "fs_csv.Map"}
+var GoTypes = []string{"interface{}", "bool", "int", "float64", "string", "fs_csv.Slice", "fs_csv.Map", "time.Time", "time.Duration"}
+
+// 键上声明的类型, 如 start:time
+var declTypes = map[string]int{
+	format.TimeKeyType:     TimeType,
+	format.DateKeyType:     TimeType,
+	format.DurationKeyType: DurationType,
+	format.TextKeyType:     StringType,
+}
 
 type Map map[interface{}]interface{}
 type Slice []interface{}
@@ -32,6 +44,7 @@ type Reader struct {
 	Defs     []string
 	Comments []string
 	Content  [][]string
+	Texts    []bool // 声明为需要本地化的文本列
 }
 
 func NewReader(name string) *Reader {
@@ -41,10 +54,19 @@ func NewReader(name string) *Reader {
 	panic("unknow file type")
 }
 
-func checkAllKeyTypes(keys []string, defs []string, content [][]string, name string) []int {
+// checkAllKeyTypes 推断各列的类型, 时间与时长只能由键声明, 声明的列会检查每个值能否解析
+func checkAllKeyTypes(keys []string, declared []int, defs []string, content [][]string, name string) ([]int, error) {
 	ret := make([]int, len(keys))
 
 	for i, key := range keys {
+		if declared[i] != NilType {
+			if err := checkDeclared(declared[i], defs[i], content, i); err != nil {
+				return nil, fmt.Errorf("%s field %s: %v", name, key, err)
+			}
+			ret[i] = declared[i]
+			continue
+		}
+
 		//if strings.Index(key, "_") > -1 {
 		//	continue
 		//}
@@ -73,7 +95,7 @@ func checkAllKeyTypes(keys []string, defs []string, content [][]string, name str
 				if t1+t2 == IntType+FloatType && t1*t2 == IntType*FloatType {
 					typ = FloatType
 				} else {
-					panic(fmt.Sprintf("%s field %s type %d not same as default %d", name, key, fieldType, typ))
+					return nil, fmt.Errorf("%s field %s type %d not same as default %d", name, key, fieldType, typ)
 				}
 			}
 		} else {
@@ -81,7 +103,30 @@ func checkAllKeyTypes(keys []string, defs []string, content [][]string, name str
 		}
 		ret[i] = typ
 	}
-	return ret
+	return ret, nil
+}
+
+func checkDeclared(typ int, def string, content [][]string, i int) error {
+	values := []string{def}
+	for _, row := range content {
+		values = append(values, row[i])
+	}
+	for _, v := range values {
+		if len(strings.TrimSpace(v)) == 0 {
+			continue
+		}
+		var err error
+		switch typ {
+		case TimeType:
+			_, err = times.ParseTime(v)
+		case DurationType:
+			_, err = times.ParseDuration(v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func ConvType(v string) interface{} {
@@ -129,6 +174,40 @@ func ConvType(v string) interface{} {
 	return nil
 }
 
+// ConvKeyType 按列类型转换, 时间与时长列会解析数字形式的值, 如 20261001、3600, 字符串列保持原文
+func ConvKeyType(v string, typ int) interface{} {
+	switch typ {
+	case TimeType:
+		if len(strings.TrimSpace(v)) == 0 {
+			return nil
+		}
+		t, err := times.ParseTime(v)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	case DurationType:
+		if len(strings.TrimSpace(v)) == 0 {
+			return nil
+		}
+		d, err := times.ParseDuration(v)
+		if err != nil {
+			panic(err)
+		}
+		return d
+	case StringType:
+		if whatType(v) == NilType {
+			return nil
+		}
+		if isString(v) {
+			return v[1 : len(v)-1]
+		}
+		return v
+	default:
+		return ConvType(v)
+	}
+}
+
 func whatType(v string) int {
 	v = strings.TrimSpace(v)
 	if len(v) == 0 {
@@ -198,16 +277,19 @@ func isJSON(v string) bool {
 }
 
 func NewCsvReader(name string) *Reader {
-	defer func() {
-		if err := recover(); err != nil {
-			fmt.Printf("error in file %s\n", name)
-			panic(err)
-		}
-	}()
+	reader, err := ReadCsv(name)
+	if err != nil {
+		fmt.Printf("error in file %s\n", name)
+		panic(err)
+	}
+	return reader
+}
 
+// ReadCsv 读取 csv 配置, 文件无法读取或列的类型不一致时返回错误
+func ReadCsv(name string) (*Reader, error) {
 	file, err := os.Open(name)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer file.Close()
 
@@ -215,7 +297,7 @@ func NewCsvReader(name string) *Reader {
 	br := bufio.NewReader(file)
 	r, _, err := br.ReadRune()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %v", name, err)
 	}
 	if r != '\uFEFF' {
 		br.UnreadRune()
@@ -224,7 +306,10 @@ func NewCsvReader(name string) *Reader {
 
 	content, err := csv.NewReader(br).ReadAll()
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(content) < 3 {
+		return nil, fmt.Errorf("%s missing key, default or comment row", name)
 	}
 
 	valid := make([]int, 0, len(content[0]))
@@ -256,6 +341,14 @@ func NewCsvReader(name string) *Reader {
 		Column: len(valid),
 	}
 	reader.Keys = validFunc(content[0])
+	declared := make([]int, len(reader.Keys))
+	reader.Texts = make([]bool, len(reader.Keys))
+	for i, key := range reader.Keys {
+		var typ string
+		reader.Keys[i], typ = format.SplitKeyType(key)
+		declared[i] = declTypes[typ]
+		reader.Texts[i] = typ == format.TextKeyType
+	}
 	reader.Defs = validFunc(content[1])
 	reader.Comments = validFunc(content[2])
 	reader.Content = make([][]string, 0, len(content)-3)
@@ -265,7 +358,9 @@ func NewCsvReader(name string) *Reader {
 		}
 		reader.Content = append(reader.Content, validFunc(col))
 	}
-	reader.KeyTypes = checkAllKeyTypes(reader.Keys, reader.Defs, reader.Content, name)
-
-	return reader
+	reader.KeyTypes, err = checkAllKeyTypes(reader.Keys, declared, reader.Defs, reader.Content, name)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
 }
@@ -1,33 +1,25 @@
 package datapack
 
 import (
-	"bufio"
-	stdjson "encoding/json"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/youngpto/funs_tool/algorithm"
 	"github.com/youngpto/funs_tool/coll/sets/hashset"
-	"github.com/youngpto/funs_tool/coll_utils"
-	"github.com/youngpto/funs_tool/datapack/csv"
 	"github.com/youngpto/funs_tool/datapack/format"
 	"github.com/youngpto/funs_tool/datapack/json"
 	utils "github.com/youngpto/funs_tool/os"
-	"io"
+	goformat "go/format"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 )
 
-var allowFileType = []string{
-	".json",
-	//".yaml",
-	".csv",
-}
-
 type inode struct {
 	name  string
 	isdir bool
@@ -39,14 +31,78 @@ type inode struct {
 	structname  string
 	variatename string
 
-	isJsonArray   bool
-	jsonArrayType int
+	parser SourceParser
+	table  *Table
+	shards []*inode
 
 	binary *json.Object
 	sync.Mutex
 }
 
-func (i *inode) gen() (structSpec, bool) {
+func (i *inode) load() *Table {
+	i.Lock()
+	defer i.Unlock()
+	if i.table == nil {
+		var table *Table
+		var err error
+		if i.shards != nil {
+			table, err = i.mergeShards()
+		} else {
+			table, err = i.parser.Parse(i.path)
+		}
+		if err != nil {
+			panic(err)
+		}
+		i.table = table
+	}
+	return i.table
+}
+
+// fieldType 节点在上级结构中的字段类型
+func (i *inode) fieldType() string {
+	typ := fmt.Sprintf("*%s", i.structname)
+	if i.isdir {
+		return typ
+	}
+	table := i.load()
+	switch table.Layout {
+	case RowsLayout:
+		typ = fmt.Sprintf("map[int]%s", typ)
+	case ObjectLayout:
+		typ = fmt.Sprintf("map[string]%s", typ)
+	case ArrayLayout:
+		if table.Elem != "" {
+			typ = table.Elem
+		}
+		typ = fmt.Sprintf("map[string][]%s", typ)
+	}
+	return typ
+}
+
+// tableSpec 文件节点的表描述, 用于生成校验注册代码
+func (i *inode) tableSpec() tableSpec {
+	table := i.load()
+	spec := tableSpec{
+		Name:    i.structname,
+		Path:    prettycomment(i.path),
+		Type:    i.fieldType(),
+		KeyType: "string",
+		RowType: fmt.Sprintf("*%s", i.structname),
+		table:   table,
+	}
+	if table.Layout == RowsLayout {
+		spec.KeyType = "int"
+	}
+	if table.Elem != "" {
+		spec.RowType = table.Elem
+	}
+	for n := i; n.prev != nil; n = n.prev {
+		spec.Access = append([]string{n.variatename}, spec.Access...)
+	}
+	return spec
+}
+
+func (i *inode) gen(localize bool) (structSpec, bool) {
 	spec := structSpec{
 		Name:    i.structname,
 		VName:   i.variatename,
@@ -58,183 +114,43 @@ func (i *inode) gen() (structSpec, bool) {
 		}
 
 		spec.Fields = make([]fieldSpec, 0, len(i.nodes))
-		mergeJson := make(map[string]struct{})
 		for _, nn := range i.nodes {
-			typ := fmt.Sprintf("*%s", nn.structname)
-			if !nn.isdir {
-				switch nn.ext {
-				case ".csv":
-					typ = fmt.Sprintf("map[int]%s", typ)
-				case ".json":
-					idx := strings.LastIndex(typ, "_")
-					if idx >= 0 && idx+1 < len(typ) {
-						_, err := strconv.ParseInt(typ[idx+1:], 10, 64)
-						if err == nil {
-							typ = typ[:idx]
-						}
-					}
-
-					if coll_utils.Inmap(typ, mergeJson) {
-						continue
-					}
-
-					mergeJson[typ] = struct{}{}
-
-					jsonType := json.ValidJSONFile(nn.path)
-					if jsonType == json.ArrayType {
-						jsonArray := json.LoadJSONArray(nn.path)
-						eleTyp, _ := json.CheckArray(jsonArray)
-						if eleTyp != json.MapType {
-							typ = fmt.Sprintf("[]%s", json.GoTypes[eleTyp])
-						} else {
-							typ = fmt.Sprintf("[]%s", typ)
-						}
-						nn.isJsonArray = true
-						nn.jsonArrayType = eleTyp
-					}
-
-					typ = fmt.Sprintf("map[string]%s", typ)
-				}
-			}
 			spec.Fields = append(spec.Fields, fieldSpec{
 				Name:    nn.variatename,
-				Type:    typ,
+				Type:    nn.fieldType(),
 				Comment: prettycomment(nn.path),
 				Tag:     format.Tag(nn.name),
 			})
 		}
 	} else {
-		switch i.ext {
-		case ".json":
-			var structName = i.name
-			var jsonObj interface{}
-			if i.isJsonArray {
-				jsonObj = json.LoadJSONArray(i.path)
+		table := i.load()
+		if i.prev != nil {
+			if table.Layout == RowsLayout {
+				i.prev.binary.Set(i.name, table.Data)
 			} else {
-				jsonObj = json.LoadJSONObject(i.path)
-			}
-			defer func() {
-				if i.prev != nil {
-					obj := i.prev.binary.SetDefault(structName, json.NewObject()).(*json.Object)
-					obj.Set(i.name, jsonObj)
-				}
-			}()
-
-			idx := strings.LastIndex(i.structname, "_")
-			if idx >= 0 && idx+1 < len(i.structname) {
-				_, err := strconv.ParseInt(i.structname[idx+1:], 10, 64)
-				if err == nil {
-					structName = i.name[:idx]
-					return spec, false
-				}
-			}
-
-			if i.isJsonArray && i.jsonArrayType != json.MapType {
-				return spec, false
+				i.prev.binary.Set(i.name, i.binary)
 			}
 		}
-		spec.Fields = i.parseFields()
-	}
-
-	return spec, true
-}
-
-func (i *inode) parseFields() []fieldSpec {
-	switch i.ext {
-	case ".csv":
-		return i.parseCSVFields()
-	case ".json":
-		return i.parseJSONFields()
-	default:
-		var specs = []fieldSpec{
-			{
-				Name:    "F1",
-				Type:    "int",
-				Comment: "is f1",
-				Tag:     format.Tag("F1"),
-			},
-			{
-				Name:    "F2",
-				Type:    "string",
-				Comment: "is f2",
-				Tag:     format.Tag("F2"),
-			},
+		if table.Elem != "" {
+			return spec, false
 		}
-		return specs
-	}
-}
-
-func (i *inode) parseJSONFields() []fieldSpec {
-	var specs []fieldSpec
 
-	var obj = json.NewObject()
-	if i.isJsonArray {
-		jsonArray := json.LoadJSONArray(i.path)
-		obj, _ = jsonArray.GetObject(0)
-	} else {
-		obj = json.LoadJSONObject(i.path)
-	}
-	gens := json.ParseJSONObject(obj)
-	for _, gen := range gens {
-		specs = append(specs, fieldSpec{
-			Name:    gen.Key,
-			Type:    gen.Type,
-			Comment: gen.Comment,
-			Tag:     gen.Tag,
-		})
-	}
-	return specs
-}
-
-func (i *inode) parseCSVFields() []fieldSpec {
-	var specs []fieldSpec
-	reader := csv.NewReader(i.path)
-	keys := reader.Keys
-	keyTypes := reader.KeyTypes
-	specs = make([]fieldSpec, 0, len(keys))
-	for j, key := range keys {
-		//if strings.Index(key, "_") > -1 {
-		//	continue
-		//}
-		var typ int
-		if j == 0 {
-			key = "ID"
-			typ = csv.IntType
-		} else {
-			typ = keyTypes[j]
-		}
-
-		spec := fieldSpec{
-			Name:    format.Title(key),
-			Type:    csv.GoTypes[typ],
-			Comment: reader.Comments[j],
-			Tag:     format.Tag(key),
-		}
-		specs = append(specs, spec)
-	}
-
-	if i.prev != nil {
-		csvMap := make(map[int]*json.Object)
-		for _, values := range reader.Content {
-			record := json.NewObject()
-			for idx, value := range values {
-				if idx == 0 {
-					id, _ := strconv.Atoi(value)
-					record.Set("ID", id)
-				} else {
-					conVal := csv.ConvType(value)
-					if conVal == nil {
-						conVal = csv.ConvType(reader.Defs[idx])
-					}
-					record.Set(keys[idx], conVal)
-				}
+		spec.Fields = make([]fieldSpec, 0, len(table.Fields))
+		for _, field := range table.Fields {
+			typ := field.Type
+			if field.Text && localize {
+				typ = TextType
 			}
-			id, _ := record.GetInt("ID")
-			csvMap[id] = record
+			spec.Fields = append(spec.Fields, fieldSpec{
+				Name:    fieldName(field.Name),
+				Type:    typ,
+				Comment: field.Comment,
+				Tag:     format.Tag(field.Name),
+			})
 		}
-		i.prev.binary.Set(i.name, csvMap)
 	}
-	return specs
+
+	return spec, true
 }
 
 /*
@@ -243,39 +159,39 @@ var genFile = "./conf/generated.go"
 var msgpackFile = "./conf/msgpack.json"
 */
 
-func Conf2Src(rootPath string, genFile string, msgpackFile string) error {
-	prefix = filepath.ToSlash(filepath.Join(rootPath, ""))
-	start := time.Now().Unix()
+func Conf2Src(rootPath string, genFile string, msgpackFile string, opts ...Option) error {
+	o := newOptions(opts)
+	start := time.Now()
 	defer func() {
-		end := time.Now().Unix()
-		fmt.Printf("cost time %ds:\n", end-start)
+		o.logf(1, "cost time %ds:\n", int(time.Since(start).Seconds()))
 	}()
 
-	root := &inode{
-		name:       "config",
-		isdir:      true,
-		path:       rootPath,
-		structname: "gameConfig",
-		binary:     json.NewObject(),
-	}
-	exist := hashset.New[string]()
-	visit(rootPath, root, exist)
-	conf2go(root, genFile)
-	_ = utils.SysRun(os.Stdout, os.Stderr, "gofmt", "-l", "-w", "-e", genFile)
-
-	bytes, err := stdjson.Marshal(root.binary)
+	out, err := Generate(rootPath, genFile, msgpackFile, opts...)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	err = ioutil.WriteFile(msgpackFile, bytes, 0644)
-	if err != nil {
-		panic(err)
+	if err = out.Write(genFile, msgpackFile); err != nil {
+		return err
+	}
+	for _, locale := range o.locales {
+		if keys := out.Missing[locale]; len(keys) > 0 {
+			o.logf(1, "missing %d translations for %s\n", len(keys), locale)
+			for _, key := range keys {
+				o.logf(2, "  %s\n", key)
+			}
+		}
 	}
 
+	if len(o.check) > 0 {
+		args := append(o.check[1:len(o.check):len(o.check)], msgpackFile)
+		if err = utils.SysRun(os.Stdout, os.Stderr, o.check[0], args...); err != nil {
+			return fmt.Errorf("check %s: %v", msgpackFile, err)
+		}
+	}
 	return nil
 }
 
-func visit(path string, parent *inode, exist *hashset.Set[string]) {
+func visit(path string, parent *inode, exist *hashset.Set[string], o *options) {
 	files, _ := ioutil.ReadDir(path)
 	parent.nodes = make([]*inode, 0, len(files))
 
@@ -285,15 +201,17 @@ func visit(path string, parent *inode, exist *hashset.Set[string]) {
 			continue
 		}
 
+		var parser SourceParser
 		if !file.IsDir() {
-			if !coll_utils.In(filepath.Ext(name), allowFileType) {
-				fmt.Printf("not register file type from %s \n", name)
+			var ok bool
+			if parser, ok = o.parser(filepath.Ext(name)); !ok {
+				o.logf(1, "not register file type from %s \n", name)
 				continue
 			}
 		}
 
 		fpath := filepath.Join(path, name)
-		fmt.Println("visit", fpath)
+		o.logf(2, "visit %s\n", fpath)
 
 		n := strings.Split(filepath.Base(name), ".")[0]
 		subpath := strings.Split(fpath, ".")[0]
@@ -310,6 +228,7 @@ func visit(path string, parent *inode, exist *hashset.Set[string]) {
 			path:        fpath,
 			structname:  structname,
 			variatename: format.Title(n),
+			parser:      parser,
 			binary:      json.NewObject(),
 		}
 		if !file.IsDir() {
@@ -319,9 +238,10 @@ func visit(path string, parent *inode, exist *hashset.Set[string]) {
 		parent.nodes = append(parent.nodes, node)
 
 		if file.IsDir() {
-			visit(fpath, node, exist)
+			visit(fpath, node, exist, o)
 		}
 	}
+	groupShards(parent, exist)
 }
 
 var prefix string
@@ -343,24 +263,113 @@ func prettycomment(s string) string {
 }
 
 var goTmpl = `// Code generated - DO NOT EDIT.
-package conf
+package {{ .Package }}
 
 import (
+{{- if .Embed }}
+	_ "embed"
+{{- end }}
+	"encoding/json"
+{{- if .Bundle }}
+	fs_bundle "github.com/youngpto/funs_tool/datapack/bundle"
+{{- end }}
 	fs_csv "github.com/youngpto/funs_tool/datapack/csv"
+	fs_i18n "github.com/youngpto/funs_tool/datapack/i18n"
 	fs_json "github.com/youngpto/funs_tool/datapack/json"
+	fs_reload "github.com/youngpto/funs_tool/datapack/reload"
+	fs_validate "github.com/youngpto/funs_tool/datapack/validate"
+	"time"
 )
 
+// SchemaHash 配置结构哈希, 与打包文件头中的值一致时才能解码
+const SchemaHash = "{{ .SchemaHash }}"
+{{ if .Embed }}
+//go:embed {{ .Embed }}
+var embedded []byte
+
+// LoadEmbedded 加载嵌入的配置
+func LoadEmbedded() (*gameConfig, error) {
+	return Load(embedded)
+}
+{{ end }}
 var assertCsv fs_csv.Reader
 var assertJson fs_json.Object
+var assertTime time.Time
+var assertText fs_i18n.Text
 
-{{range .}}// {{ .Comment }}
+{{range .Structs}}// {{ .Comment }}
 type {{ .Name }} struct {
 {{ range .Fields }}	// {{ .Comment }}
 	{{ .Name }} {{ .Type }} {{ .Tag }}
 {{ end }}}
 
 {{ end }}
-`
+var validators = newValidators()
+
+func newValidators() *fs_validate.Registry {
+	r := fs_validate.NewRegistry()
+{{ range .Tables }}	r.Table("{{ .Path }}"{{ range .Access }}, "{{ . }}"{{ end }})
+{{ end }}	return r
+}
+
+// Load 解码配置并执行校验
+func Load(data []byte) (*gameConfig, error) {
+{{- if .Bundle }}
+	data, err := fs_bundle.Open(data, SchemaHash)
+	if err != nil {
+		return nil, err
+	}
+{{- end }}
+	cfg := new(gameConfig)
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Config 配置根结构, 供包外引用
+type Config = gameConfig
+
+// NewReloader 创建热更新器, 打包文件变化时在旁路解码校验, 成功后原子替换, 失败时保留旧配置
+func NewReloader(path string, opts ...fs_reload.Option) (*fs_reload.Reloader[*gameConfig], error) {
+	return fs_reload.New[*gameConfig](path, Load, opts...)
+}
+
+// Validate 执行全部已注册的校验, 错误为 fs_validate.Errors
+func Validate(cfg *gameConfig) error {
+	return validators.Run(cfg)
+}
+
+// ValidateBlob 解码并校验打包数据, 可用于校验命令 fs_validate.Main(conf.ValidateBlob)
+func ValidateBlob(data []byte) error {
+	_, err := Load(data)
+	return err
+}
+
+// RegisterValidator 注册整体校验
+func RegisterValidator(f func(cfg *gameConfig) error) {
+	validators.OnConfig(func(cfg interface{}) error {
+		return f(cfg.(*gameConfig))
+	})
+}
+{{ range .Tables }}
+// Register{{ .Name }}Validator 注册 {{ .Path }} 的行校验
+func Register{{ .Name }}Validator(f func(key {{ .KeyType }}, row {{ .RowType }}) error) {
+	validators.OnRow("{{ .Path }}", func(key, row interface{}) error {
+		return f(key.({{ .KeyType }}), row.({{ .RowType }}))
+	})
+}
+
+// Register{{ .Name }}TableValidator 注册 {{ .Path }} 的整表校验
+func Register{{ .Name }}TableValidator(f func(table {{ .Type }}) error) {
+	validators.OnTable("{{ .Path }}", func(table interface{}) error {
+		return f(table.({{ .Type }}))
+	})
+}
+{{ end }}`
 
 type structSpec struct {
 	Name    string
@@ -370,6 +379,17 @@ type structSpec struct {
 	Fields []fieldSpec
 }
 
+type tableSpec struct {
+	Name    string
+	Path    string
+	Access  []string
+	Type    string
+	KeyType string
+	RowType string
+
+	table *Table
+}
+
 type fieldSpec struct {
 	Name    string
 	Type    string
@@ -377,40 +397,54 @@ type fieldSpec struct {
 	Tag     string
 }
 
-func conf2go(root *inode, out string) {
+// schemaHash 由生成的结构计算哈希, 结构变化后旧的打包文件无法加载
+func schemaHash(specs []structSpec) [32]byte {
+	h := sha256.New()
+	for _, spec := range specs {
+		fmt.Fprintf(h, "%s{", spec.Name)
+		for _, field := range spec.Fields {
+			fmt.Fprintf(h, "%s %s %s;", field.Name, field.Type, field.Tag)
+		}
+		fmt.Fprint(h, "}")
+	}
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+func conf2go(root *inode, o *options, embed string) ([]byte, [32]byte, []tableSpec, error) {
 	var structSpecs = make([]structSpec, 0)
+	var tableSpecs = make([]tableSpec, 0)
 	algorithm.DFS(root, func(pop *inode) []*inode {
-		if gen, ok := pop.gen(); ok {
+		if gen, ok := pop.gen(o.localize()); ok {
 			structSpecs = append(structSpecs, gen)
 		}
-		return pop.nodes
-	})
-
-	write2File(out, func(writer io.Writer) {
-		tmpl, err := template.New("go.tmpl").Parse(goTmpl)
-		if err != nil {
-			panic(err)
-		}
-
-		bw := bufio.NewWriter(writer)
-		err = tmpl.Execute(bw, structSpecs)
-		if err != nil {
-			bw.Flush()
-			panic(err)
-		}
-		err = bw.Flush()
-		if err != nil {
-			panic(err)
+		if !pop.isdir {
+			tableSpecs = append(tableSpecs, pop.tableSpec())
 		}
+		return pop.nodes
 	})
-}
+	schema := schemaHash(structSpecs)
 
-func write2File(name string, do func(writer io.Writer)) {
-	os.Remove(name)
-	file, err := os.Create(name)
+	tmpl, err := template.New("go.tmpl").Parse(goTmpl)
+	if err != nil {
+		return nil, schema, nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Package    string
+		Structs    []structSpec
+		Tables     []tableSpec
+		SchemaHash string
+		Bundle     bool
+		Embed      string
+	}{o.pkg, structSpecs, tableSpecs, hex.EncodeToString(schema[:]), o.bundle, embed})
+	if err != nil {
+		return nil, schema, nil, err
+	}
+	src, err := goformat.Source(buf.Bytes())
 	if err != nil {
-		panic(err.Error())
+		return nil, schema, nil, fmt.Errorf("format generated code: %v", err)
 	}
-	defer file.Close()
-	do(file)
+	return src, schema, tableSpecs, nil
 }
@@ -0,0 +1,31 @@
+package format
+
+import "strings"
+
+// 键上可声明的类型, 如 start:time、cd:duration、title:text
+const (
+	TimeKeyType     = "time"
+	DateKeyType     = "date"
+	DurationKeyType = "duration"
+	TextKeyType     = "text" // 需要本地化的文本
+)
+
+var keyTypes = map[string]struct{}{
+	TimeKeyType:     {},
+	DateKeyType:     {},
+	DurationKeyType: {},
+	TextKeyType:     {},
+}
+
+// SplitKeyType 拆分键名与声明的类型, 未声明或类型未知时原样返回键名
+func SplitKeyType(key string) (string, string) {
+	idx := strings.LastIndex(key, ":")
+	if idx <= 0 {
+		return key, ""
+	}
+	typ := strings.ToLower(strings.TrimSpace(key[idx+1:]))
+	if _, ok := keyTypes[typ]; !ok {
+		return key, ""
+	}
+	return strings.TrimSpace(key[:idx]), typ
+}
@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+/*
+	i18n.LoadFile("en", "./conf/lang/en.json")
+	i18n.LoadFile("cn", "./conf/lang/cn.json")
+	i18n.SetFallback("cn")
+	i18n.SetLanguage("en")
+	name := cfg.Item.Weapon[1].Name.String()
+*/
+
+// Text 配置中的本地化文本键, 通过 String 取当前语言的文本
+type Text string
+
+func (t Text) String() string {
+	return Lookup(string(t))
+}
+
+// In 取指定语言的文本
+func (t Text) In(lang string) string {
+	if s, ok := LookupIn(lang, string(t)); ok {
+		return s
+	}
+	return string(t)
+}
+
+var (
+	mu       sync.RWMutex
+	tables   = make(map[string]map[string]string)
+	language string
+	fallback string
+)
+
+// SetLanguage 设置当前语言
+func SetLanguage(lang string) {
+	mu.Lock()
+	defer mu.Unlock()
+	language = lang
+}
+
+// Language 当前语言
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return language
+}
+
+// SetFallback 当前语言缺少翻译时使用的语言
+func SetFallback(lang string) {
+	mu.Lock()
+	defer mu.Unlock()
+	fallback = lang
+}
+
+// Set 替换一种语言的字符串表
+func Set(lang string, table map[string]string) {
+	mu.Lock()
+	defer mu.Unlock()
+	tables[lang] = table
+}
+
+// Load 加载 json 格式的字符串表
+func Load(lang string, data []byte) error {
+	table := make(map[string]string)
+	if err := json.Unmarshal(data, &table); err != nil {
+		return err
+	}
+	Set(lang, table)
+	return nil
+}
+
+func LoadFile(lang string, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return Load(lang, data)
+}
+
+// LookupIn 取指定语言的文本, 空字符串视为缺少翻译
+func LookupIn(lang string, key string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := tables[lang][key]
+	return s, ok && s != ""
+}
+
+// Lookup 依次在当前语言、回退语言中查找, 都没有时返回键本身
+func Lookup(key string) string {
+	mu.RLock()
+	lang, fb := language, fallback
+	mu.RUnlock()
+
+	if s, ok := LookupIn(lang, key); ok {
+		return s
+	}
+	if s, ok := LookupIn(fb, key); ok {
+		return s
+	}
+	return key
+}
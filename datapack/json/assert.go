@@ -7,8 +7,10 @@ import (
 	"github.com/youngpto/funs_tool/datapack/format"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"text/template"
+	"time"
 )
 
 const (
@@ -19,9 +21,11 @@ const (
 	StringType
 	ArrayType
 	MapType
+	TimeType
+	DurationType
 )
 
-var GoTypes = []string{"interface{}", "bool", "int", "float64", "string", "*fs_json.Object", "*fs_json.Array"}
+var GoTypes = []string{"interface{}", "bool", "int", "float64", "string", "*fs_json.Object", "*fs_json.Array", "time.Time", "time.Duration"}
 
 type Object struct {
 	content map[string]interface{}
@@ -66,45 +70,27 @@ func (o *Object) Get(key string) interface{} {
 }
 
 func (o *Object) GetInt(key string) (r int, b bool) {
-	in := o.Get(key)
-	r, b = in.(int)
-	return
+	return Get[int](o, key)
 }
 
 func (o *Object) GetString(key string) (r string, b bool) {
-	in := o.Get(key)
-	r, b = in.(string)
-	return
+	return Get[string](o, key)
 }
 
 func (o *Object) GetBool(key string) (r bool, b bool) {
-	in := o.Get(key)
-	r, b = in.(bool)
-	return
+	return Get[bool](o, key)
 }
 
 func (o *Object) GetFloat(key string) (r float64, b bool) {
-	in := o.Get(key)
-	r, b = in.(float64)
-	return
+	return Get[float64](o, key)
 }
 
 func (o *Object) GetObject(key string) (r *Object, b bool) {
-	in := o.Get(key)
-	m, ok := in.(map[string]interface{})
-	if !ok {
-		return
-	}
-	return &Object{content: m}, true
+	return Get[*Object](o, key)
 }
 
 func (o *Object) GetArray(key string) (r *Array, b bool) {
-	in := o.Get(key)
-	m, ok := in.([]interface{})
-	if !ok {
-		return
-	}
-	return &Array{content: m}, true
+	return Get[*Array](o, key)
 }
 
 type Array struct {
@@ -145,45 +131,27 @@ func (a *Array) Get(idx int) interface{} {
 }
 
 func (a *Array) GetInt(idx int) (r int, b bool) {
-	in := a.Get(idx)
-	r, b = in.(int)
-	return
+	return Index[int](a, idx)
 }
 
 func (a *Array) GetString(idx int) (r string, b bool) {
-	in := a.Get(idx)
-	r, b = in.(string)
-	return
+	return Index[string](a, idx)
 }
 
 func (a *Array) GetBool(idx int) (r bool, b bool) {
-	in := a.Get(idx)
-	r, b = in.(bool)
-	return
+	return Index[bool](a, idx)
 }
 
 func (a *Array) GetFloat(idx int) (r float64, b bool) {
-	in := a.Get(idx)
-	r, b = in.(float64)
-	return
+	return Index[float64](a, idx)
 }
 
 func (a *Array) GetObject(idx int) (r *Object, b bool) {
-	in := a.Get(idx)
-	m, ok := in.(map[string]interface{})
-	if !ok {
-		return
-	}
-	return &Object{content: m}, true
+	return Index[*Object](a, idx)
 }
 
 func (a *Array) GetArray(idx int) (r *Array, b bool) {
-	in := a.Get(idx)
-	m, ok := in.([]interface{})
-	if !ok {
-		return
-	}
-	return &Array{content: m}, true
+	return Index[*Array](a, idx)
 }
 
 func (a *Array) String() string {
@@ -219,6 +187,10 @@ func whatType(in interface{}) int {
 		return FloatType
 	} else if isString(in) {
 		return StringType
+	} else if isTime(in) {
+		return TimeType
+	} else if isDuration(in) {
+		return DurationType
 	} else if isArray(in) {
 		return ArrayType
 	} else if isMap(in) {
@@ -247,6 +219,16 @@ func isString(in interface{}) bool {
 	return ok
 }
 
+func isTime(in interface{}) bool {
+	_, ok := in.(time.Time)
+	return ok
+}
+
+func isDuration(in interface{}) bool {
+	_, ok := in.(time.Duration)
+	return ok
+}
+
 func isArray(in interface{}) bool {
 	_, ok := in.([]interface{})
 	return ok
@@ -276,6 +258,7 @@ func genPrivateStructTyp(gens []GenSpec) string {
 }
 
 type GenSpec struct {
+	Name    string
 	Key     string
 	Type    string
 	Comment string
@@ -284,8 +267,15 @@ type GenSpec struct {
 
 func ParseJSONObject(obj *Object) []GenSpec {
 	var result []GenSpec
-	for key, value := range obj.content {
+	keys := make([]string, 0, len(obj.content))
+	for key := range obj.content {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := obj.content[key]
 		genSpec := GenSpec{
+			Name:    key,
 			Key:     format.Title(key),
 			Comment: format.Title(key),
 			Tag:     format.Tag(key),
@@ -0,0 +1,390 @@
+package json
+
+import (
+	"encoding"
+	"fmt"
+	"github.com/youngpto/funs_tool/times"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	var weapon struct {
+		Name  string       `json:"name" yaml:"name"`
+		Price float64      `json:"price" yaml:"price"`
+		Tags  fs_csv.Slice `json:"tags" yaml:"tags"`
+	}
+	err := json.Decode(obj.Get("weapon"), &weapon)
+	obj := json.Encode(weapon)
+
+字段名取 json 标签, 其次 yaml 标签, 都没有时用字段名; 键不区分大小写匹配, 缺少的键保持零值.
+时间可以是 time.Time 或时间字符串, 时长可以是 time.Duration、纳秒数或时长字符串.
+*/
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	objectPtr    = reflect.TypeOf((*Object)(nil))
+	arrayPtr     = reflect.TypeOf((*Array)(nil))
+	textType     = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeError 绑定失败的位置与原因
+type DecodeError struct {
+	Path  string
+	Type  reflect.Type
+	Value interface{}
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "$"
+	}
+	if e.Err != nil && e.Err != ErrType {
+		return fmt.Sprintf("json decode %s: cannot convert %s to %s: %v", path, typeName(e.Value), e.Type, e.Err)
+	}
+	return fmt.Sprintf("json decode %s: cannot convert %s to %s", path, typeName(e.Value), e.Type)
+}
+
+// Unwrap 返回具体的原因, 如时间格式错误, 没有具体原因时为 ErrType
+func (e *DecodeError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return ErrType
+}
+
+// Is 绑定失败总是匹配 ErrType
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrType
+}
+
+// Decode 将配置值(*Object、*Array 或原始的 map/slice/标量)写入 target, target 必须是非 nil 指针
+func Decode(in interface{}, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("json decode: target must be a non-nil pointer, got %T", target)
+	}
+	return decode("", unwrap(in), rv.Elem())
+}
+
+func decode(path string, in interface{}, out reflect.Value) error {
+	in = unwrap(in)
+	fail := func(err error) error {
+		return &DecodeError{Path: strings.TrimPrefix(path, "."), Type: out.Type(), Value: in, Err: err}
+	}
+	if in == nil {
+		out.Set(reflect.Zero(out.Type()))
+		return nil
+	}
+	if v := reflect.ValueOf(in); v.Type().AssignableTo(out.Type()) && out.Kind() != reflect.Map && out.Kind() != reflect.Slice {
+		out.Set(v)
+		return nil
+	}
+
+	switch out.Type() {
+	case objectPtr, arrayPtr:
+		if v := reflect.ValueOf(wrap(in)); v.Type() == out.Type() {
+			out.Set(v)
+			return nil
+		}
+		return fail(nil)
+	case timeType:
+		s, ok := in.(string)
+		if !ok {
+			return fail(nil)
+		}
+		t, err := times.ParseTime(s)
+		if err != nil {
+			return fail(err)
+		}
+		out.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		if s, ok := in.(string); ok {
+			d, err := times.ParseDuration(s)
+			if err != nil {
+				return fail(err)
+			}
+			out.SetInt(int64(d))
+			return nil
+		}
+	}
+	if s, ok := in.(string); ok && reflect.PtrTo(out.Type()).Implements(textType) {
+		if err := out.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+
+	switch out.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(out.Type().Elem())
+		if err := decode(path, in, elem.Elem()); err != nil {
+			return err
+		}
+		out.Set(elem)
+	case reflect.Interface:
+		v := reflect.ValueOf(in)
+		if !v.Type().Implements(out.Type()) {
+			return fail(nil)
+		}
+		out.Set(v)
+	case reflect.Bool:
+		b, ok := in.(bool)
+		if !ok {
+			return fail(nil)
+		}
+		out.SetBool(b)
+	case reflect.String:
+		s, ok := in.(string)
+		if !ok {
+			return fail(nil)
+		}
+		out.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !convNumber(in, out) {
+			return fail(nil)
+		}
+	case reflect.Slice, reflect.Array:
+		v := reflect.ValueOf(in)
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return fail(nil)
+		}
+		if out.Kind() == reflect.Slice {
+			out.Set(reflect.MakeSlice(out.Type(), v.Len(), v.Len()))
+		} else if v.Len() > out.Len() {
+			return fail(fmt.Errorf("length %d exceeds %d", v.Len(), out.Len()))
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := decode(fmt.Sprintf("%s[%d]", path, i), v.Index(i).Interface(), out.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		v := reflect.ValueOf(in)
+		if v.Kind() != reflect.Map {
+			return fail(nil)
+		}
+		out.Set(reflect.MakeMapWithSize(out.Type(), v.Len()))
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			elemPath := fmt.Sprintf("%s[%v]", path, k)
+			if s, ok := k.Interface().(string); ok {
+				elemPath = path + segment{kind: keySeg, key: s}.String()
+			}
+			key := reflect.New(out.Type().Key()).Elem()
+			if err := decodeKey(k.Interface(), key); err != nil {
+				return &DecodeError{Path: strings.TrimPrefix(elemPath, "."), Type: key.Type(), Value: k.Interface(), Err: err}
+			}
+			elem := reflect.New(out.Type().Elem()).Elem()
+			if err := decode(elemPath, v.MapIndex(k).Interface(), elem); err != nil {
+				return err
+			}
+			out.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		m, ok := in.(map[string]interface{})
+		if !ok {
+			return fail(nil)
+		}
+		return decodeStruct(path, m, out)
+	default:
+		return fail(nil)
+	}
+	return nil
+}
+
+// decodeKey 对象的键总是字符串, 目标为数值类型时按数字解析
+func decodeKey(in interface{}, out reflect.Value) error {
+	s, ok := in.(string)
+	if !ok {
+		return decode("", in, out)
+	}
+	switch out.Kind() {
+	case reflect.String:
+		out.SetString(s)
+	case reflect.Interface:
+		out.Set(reflect.ValueOf(s))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, out.Type().Bits())
+		if err != nil {
+			return err
+		}
+		out.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, out.Type().Bits())
+		if err != nil {
+			return err
+		}
+		out.SetUint(n)
+	default:
+		return ErrType
+	}
+	return nil
+}
+
+func decodeStruct(path string, m map[string]interface{}, out reflect.Value) error {
+	typ := out.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, skip := fieldKey(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := decodeStruct(path, m, out.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, ok := m[name]
+		if !ok {
+			for key, v := range m {
+				if strings.EqualFold(key, name) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := decode(path+"."+name, value, out.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldKey 取字段的 json/yaml 标签名, 未导出或标签为 - 的字段跳过.
+// 与 encoding/json 相同, 未导出的嵌入字段只有不带标签名的结构体会展开, 其余跳过
+func fieldKey(field reflect.StructField) (name string, omitempty bool, skip bool) {
+	if field.PkgPath != "" && (!field.Anonymous || field.Type.Kind() != reflect.Struct) {
+		return "", false, true
+	}
+	for _, key := range []string{"json", "yaml"} {
+		tag, ok := field.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		if parts[0] == "-" && len(parts) == 1 {
+			return "", false, true
+		}
+		for _, opt := range parts[1:] {
+			omitempty = omitempty || opt == "omitempty"
+		}
+		if field.PkgPath != "" && parts[0] != "" {
+			return "", false, true
+		}
+		return parts[0], omitempty, false
+	}
+	return "", false, false
+}
+
+// Encode 将结构体或 map 转换为 *Object, 其他类型返回 nil
+func Encode(v interface{}) *Object {
+	m, ok := encode(reflect.ValueOf(v)).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return &Object{content: m}
+}
+
+func encode(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Type() {
+	case objectPtr:
+		if o := v.Interface().(*Object); o != nil {
+			return o.content
+		}
+		return nil
+	case arrayPtr:
+		if a := v.Interface().(*Array); a != nil {
+			return a.content
+		}
+		return nil
+	case timeType, durationType:
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return encode(v.Elem())
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		out := make([]interface{}, v.Len())
+		for i := range out {
+			out[i] = encode(v.Index(i))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprint(iter.Key().Interface())] = encode(iter.Value())
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		encodeStruct(v, out)
+		return out
+	}
+	return v.Interface()
+}
+
+func encodeStruct(v reflect.Value, out map[string]interface{}) {
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, omitempty, skip := fieldKey(field)
+		if skip {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			encodeStruct(v.Field(i), out)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if omitempty && v.Field(i).IsZero() {
+			continue
+		}
+		out[name] = encode(v.Field(i))
+	}
+}
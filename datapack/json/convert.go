@@ -0,0 +1,171 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+/*
+	level := json.GetOr(obj, "level", 1)
+	rate, ok := json.Get[float64](obj, "rate")
+	sub, ok := json.Get[*json.Object](obj, "sub")
+	id, err := json.Lookup[int](obj, "items[0].id")
+
+数值之间可以互相转换, 但不能损失精度: 3.0 可以转为 int, 3.5 不行. json.Number 同样适用.
+对象与数组可以是 map[string]interface{}/[]interface{}, 也可以是 *Object/*Array, 取为任意一种均可.
+*/
+
+// As 将配置值转换为 T, nil 返回 ErrMissing, 无法转换返回 ErrType
+func As[T any](in interface{}) (r T, err error) {
+	if in == nil {
+		return r, ErrMissing
+	}
+	if v, ok := in.(T); ok {
+		return v, nil
+	}
+
+	switch any(r).(type) {
+	case *Object:
+		if m, ok := unwrap(in).(map[string]interface{}); ok {
+			return any(&Object{content: m}).(T), nil
+		}
+	case *Array:
+		if s, ok := unwrap(in).([]interface{}); ok {
+			return any(&Array{content: s}).(T), nil
+		}
+	case map[string]interface{}, []interface{}:
+		if v, ok := unwrap(in).(T); ok {
+			return v, nil
+		}
+	default:
+		out := reflect.ValueOf(&r).Elem()
+		if convNumber(in, out) {
+			return r, nil
+		}
+	}
+	return r, fmt.Errorf("%w: %s is not %T", ErrType, typeName(in), r)
+}
+
+// convNumber 在不损失精度时将数值写入 out
+func convNumber(in interface{}, out reflect.Value) bool {
+	var (
+		i       int64
+		u       uint64 // 超出 int64 的无符号整数
+		f       float64
+		integer bool
+	)
+	switch v := in.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			i, integer = n, true
+		} else if n, err := v.Float64(); err == nil {
+			f = n
+		} else {
+			return false
+		}
+	default:
+		rv := reflect.ValueOf(in)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, integer = rv.Int(), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if rv.Uint() > math.MaxInt64 {
+				u = rv.Uint()
+			} else {
+				i, integer = int64(rv.Uint()), true
+			}
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		default:
+			return false
+		}
+	}
+	if u > 0 {
+		if out.Kind() < reflect.Uint || out.Kind() > reflect.Uint64 || out.OverflowUint(u) {
+			return false
+		}
+		out.SetUint(u)
+		return true
+	}
+	if !integer && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		i, integer = int64(f), true
+	}
+
+	switch out.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !integer || out.OverflowInt(i) {
+			return false
+		}
+		out.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !integer || i < 0 || out.OverflowUint(uint64(i)) {
+			return false
+		}
+		out.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		if integer {
+			f = float64(i)
+		}
+		if out.OverflowFloat(f) {
+			return false
+		}
+		out.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// Get 取对象中的值并转换为 T
+func Get[T any](o *Object, key string) (T, bool) {
+	r, err := As[T](o.Get(key))
+	return r, err == nil
+}
+
+// GetOr 取对象中的值, 缺少或无法转换时返回 def
+func GetOr[T any](o *Object, key string, def T) T {
+	if r, ok := Get[T](o, key); ok {
+		return r
+	}
+	return def
+}
+
+// Index 取数组中的值并转换为 T
+func Index[T any](a *Array, idx int) (T, bool) {
+	r, err := As[T](a.Get(idx))
+	return r, err == nil
+}
+
+// IndexOr 取数组中的值, 越界或无法转换时返回 def
+func IndexOr[T any](a *Array, idx int, def T) T {
+	if r, ok := Index[T](a, idx); ok {
+		return r
+	}
+	return def
+}
+
+// Lookup 按路径表达式取值并转换为 T, root 可以是 *Object、*Array 或原始的 map/slice
+func Lookup[T any](root interface{}, path string) (r T, err error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return
+	}
+	value, err := p.Find(root)
+	if err != nil {
+		return
+	}
+	if r, err = As[T](value); err != nil {
+		err = p.fail(p.raw, ErrType, fmt.Sprintf("%T", r), value)
+	}
+	return
+}
+
+// LookupOr 按路径表达式取值, 缺少或无法转换时返回 def
+func LookupOr[T any](root interface{}, path string, def T) T {
+	if r, err := Lookup[T](root, path); err == nil {
+		return r
+	}
+	return def
+}
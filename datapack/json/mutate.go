@@ -0,0 +1,300 @@
+package json
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func (o *Object) Has(key string) bool {
+	_, ok := o.content[key]
+	return ok
+}
+
+// Delete 删除键, 返回键是否存在
+func (o *Object) Delete(key string) bool {
+	_, ok := o.content[key]
+	delete(o.content, key)
+	return ok
+}
+
+func (o *Object) Len() int {
+	return len(o.content)
+}
+
+// Keys 排序后的键
+func (o *Object) Keys() []string {
+	return sortedKeys(o.content)
+}
+
+// Range 按键的顺序遍历, f 返回 false 时停止
+func (o *Object) Range(f func(key string, value interface{}) bool) {
+	for _, key := range o.Keys() {
+		if !f(key, o.content[key]) {
+			return
+		}
+	}
+}
+
+// Clone 深拷贝
+func (o *Object) Clone() *Object {
+	return &Object{content: clone(o.content).(map[string]interface{})}
+}
+
+// Set 替换下标处的值, 越界时返回 false
+func (a *Array) Set(idx int, value interface{}) bool {
+	if !a.inRange(idx) {
+		return false
+	}
+	a.content[idx] = value
+	return true
+}
+
+// Insert 在 idx 前插入, idx 等于长度时追加, 越界时返回 false
+func (a *Array) Insert(idx int, values ...interface{}) bool {
+	if idx < 0 || idx > len(a.content) {
+		return false
+	}
+	content := make([]interface{}, 0, len(a.content)+len(values))
+	content = append(content, a.content[:idx]...)
+	content = append(content, values...)
+	a.content = append(content, a.content[idx:]...)
+	return true
+}
+
+// Remove 删除下标处的值并返回, 越界时返回 false
+func (a *Array) Remove(idx int) (interface{}, bool) {
+	if !a.inRange(idx) {
+		return nil, false
+	}
+	value := a.content[idx]
+	a.content = append(a.content[:idx:idx], a.content[idx+1:]...)
+	return value, true
+}
+
+// Slice 返回 [start, end) 的浅拷贝, 范围会被截断到数组内
+func (a *Array) Slice(start, end int) *Array {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(a.content) {
+		end = len(a.content)
+	}
+	if start >= end {
+		return NewArray()
+	}
+	content := make([]interface{}, end-start)
+	copy(content, a.content[start:end])
+	return &Array{content: content}
+}
+
+// Range 按下标遍历, f 返回 false 时停止
+func (a *Array) Range(f func(idx int, value interface{}) bool) {
+	for idx, value := range a.content {
+		if !f(idx, value) {
+			return
+		}
+	}
+}
+
+// Clone 深拷贝
+func (a *Array) Clone() *Array {
+	return &Array{content: clone(a.content).([]interface{})}
+}
+
+func clone(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = clone(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = clone(value)
+		}
+		return out
+	case *Object:
+		return v.Clone()
+	case *Array:
+		return v.Clone()
+	}
+	return in
+}
+
+// ArrayStrategy 合并时数组的处理方式
+type ArrayStrategy int
+
+const (
+	ArrayReplace ArrayStrategy = iota // 用新数组替换
+	ArrayAppend                       // 追加到原数组之后
+	ArrayByKey                        // 按键匹配元素对象并合并, 没有匹配的追加
+)
+
+// Conflict 合并时两侧都是标量(或类型不同)且不相等时的回调, 返回最终的值, 返回错误时中止合并
+type Conflict func(path string, dst, src interface{}) (interface{}, error)
+
+type MergeOption func(opts *mergeOptions)
+
+type mergeOptions struct {
+	strategy ArrayStrategy
+	key      string
+	paths    map[string]ArrayStrategy
+	conflict Conflict
+}
+
+// WithArrayStrategy 数组的默认合并方式, 默认 ArrayReplace
+func WithArrayStrategy(strategy ArrayStrategy) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.strategy = strategy
+	}
+}
+
+// WithPathStrategy 指定路径(如 item.drops)上数组的合并方式
+func WithPathStrategy(path string, strategy ArrayStrategy) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.paths[path] = strategy
+	}
+}
+
+// WithMergeKey ArrayByKey 匹配元素使用的键, 默认 id
+func WithMergeKey(key string) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.key = key
+	}
+}
+
+// WithConflict 冲突回调, 默认使用 src 的值
+func WithConflict(f Conflict) MergeOption {
+	return func(opts *mergeOptions) {
+		opts.conflict = f
+	}
+}
+
+// Merge 将 src 深度合并到 o, 对象按键递归合并, 数组按策略处理, src 中的值会被拷贝. 出错时 o 保持不变, src 为 nil 时不做任何修改
+func (o *Object) Merge(src *Object, opts ...MergeOption) error {
+	if src == nil {
+		return nil
+	}
+	mo := &mergeOptions{
+		key:   "id",
+		paths: make(map[string]ArrayStrategy),
+	}
+	for _, opt := range opts {
+		opt(mo)
+	}
+	merged, err := mo.merge("", clone(o.content), src.content)
+	if err != nil {
+		return err
+	}
+	o.content = merged.(map[string]interface{})
+	return nil
+}
+
+func (mo *mergeOptions) merge(path string, dst, src interface{}) (interface{}, error) {
+	dst, src = unwrap(dst), unwrap(src)
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range sortedKeys(s) {
+			sub := key
+			if path != "" {
+				sub = path + "." + key
+			}
+			old, exist := d[key]
+			if !exist {
+				d[key] = clone(s[key])
+				continue
+			}
+			value, err := mo.merge(sub, old, s[key])
+			if err != nil {
+				return nil, err
+			}
+			d[key] = value
+		}
+		return d, nil
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			break
+		}
+		strategy, ok := mo.paths[path]
+		if !ok {
+			strategy = mo.strategy
+		}
+		switch strategy {
+		case ArrayAppend:
+			return append(d, clone(s).([]interface{})...), nil
+		case ArrayByKey:
+			return mo.mergeByKey(path, d, s)
+		}
+		return clone(s), nil
+	}
+
+	if dst == nil || equal(dst, src) || mo.conflict == nil {
+		return clone(src), nil
+	}
+	value, err := mo.conflict(path, dst, src)
+	if err != nil {
+		return nil, fmt.Errorf("merge %s: %w", path, err)
+	}
+	return value, nil
+}
+
+func (mo *mergeOptions) mergeByKey(path string, dst, src []interface{}) (interface{}, error) {
+	index := make(map[interface{}]int)
+	for i, elem := range dst {
+		if key, ok := mo.elemKey(elem); ok {
+			index[key] = i
+		}
+	}
+	for _, elem := range src {
+		key, ok := mo.elemKey(elem)
+		i, exist := index[key]
+		if !ok || !exist {
+			if ok {
+				index[key] = len(dst)
+			}
+			dst = append(dst, clone(elem))
+			continue
+		}
+		value, err := mo.merge(fmt.Sprintf("%s[%d]", path, i), dst[i], elem)
+		if err != nil {
+			return nil, err
+		}
+		dst[i] = value
+	}
+	return dst, nil
+}
+
+// elemKey 元素对象的匹配键, 数值统一为 float64
+func (mo *mergeOptions) elemKey(elem interface{}) (interface{}, bool) {
+	m, ok := unwrap(elem).(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	key, ok := m[mo.key]
+	if !ok || key == nil {
+		return nil, false
+	}
+	if f, ok := toFloat(key); ok {
+		return f, true
+	}
+	switch key.(type) {
+	case string, bool:
+		return key, true
+	}
+	return nil, false
+}
+
+func equal(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,681 @@
+package json
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+路径表达式
+
+	a.b[3].c         键与下标, 负下标从末尾计数
+	a["x.y"].c       带特殊字符的键用引号
+	a.*.c  a[*].c    通配对象的所有值或数组的所有元素
+	a[?id==3].name   过滤数组元素(或对象的值), 支持 == != < <= > >=, [?id] 表示字段存在
+	a[?type=="gun"]  字面量可以是数字、字符串、true、false、null
+
+路径可以 $ 开头. 确定的路径段上缺少键会返回 ErrMissing, 类型不符返回 ErrType;
+通配与过滤之后的分支不满足时直接跳过.
+*/
+
+var (
+	ErrMissing = errors.New("not found")
+	ErrType    = errors.New("wrong type")
+)
+
+// PathError 路径访问错误, At 为出错位置之前的路径
+type PathError struct {
+	Path string
+	At   string
+	Want string
+	Got  string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	at := e.At
+	if at == "" {
+		at = "$"
+	}
+	if e.Err == ErrType {
+		return fmt.Sprintf("json path %s: %s is %s, want %s", e.Path, at, e.Got, e.Want)
+	}
+	return fmt.Sprintf("json path %s: %s %v", e.Path, at, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+type segKind int
+
+const (
+	keySeg segKind = iota
+	indexSeg
+	wildcardSeg
+	filterSeg
+)
+
+type segment struct {
+	kind   segKind
+	key    string
+	index  int
+	filter *filter
+}
+
+func (s segment) String() string {
+	switch s.kind {
+	case keySeg:
+		if strings.ContainsAny(s.key, ".[]*?\"") {
+			return fmt.Sprintf("[%q]", s.key)
+		}
+		return "." + s.key
+	case indexSeg:
+		return fmt.Sprintf("[%d]", s.index)
+	case wildcardSeg:
+		return "[*]"
+	default:
+		return fmt.Sprintf("[?%s]", s.filter.raw)
+	}
+}
+
+// Path 解析后的路径表达式, 可重复使用
+type Path struct {
+	raw  string
+	segs []segment
+}
+
+func (p *Path) String() string {
+	return p.raw
+}
+
+// ParsePath 解析路径表达式
+func ParsePath(expr string) (*Path, error) {
+	p := &Path{raw: expr}
+	s := strings.TrimPrefix(expr, "$")
+	first := true
+	for len(s) > 0 {
+		switch {
+		case s[0] == '.':
+			s = s[1:]
+			fallthrough
+		case first && s[0] != '[':
+			n := strings.IndexAny(s, ".[")
+			if n < 0 {
+				n = len(s)
+			}
+			name := s[:n]
+			if name == "" {
+				return nil, fmt.Errorf("json path %s: empty key", expr)
+			}
+			if name == "*" {
+				p.segs = append(p.segs, segment{kind: wildcardSeg})
+			} else {
+				p.segs = append(p.segs, segment{kind: keySeg, key: name})
+			}
+			s = s[n:]
+		case s[0] == '[':
+			seg, rest, err := parseBracket(s[1:])
+			if err != nil {
+				return nil, fmt.Errorf("json path %s: %v", expr, err)
+			}
+			p.segs = append(p.segs, seg)
+			s = rest
+		default:
+			return nil, fmt.Errorf("json path %s: unexpected %q", expr, s[0])
+		}
+		first = false
+	}
+	return p, nil
+}
+
+// MustPath 解析路径表达式, 失败时 panic
+func MustPath(expr string) *Path {
+	p, err := ParsePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func parseBracket(s string) (segment, string, error) {
+	switch {
+	case strings.HasPrefix(s, "*]"):
+		return segment{kind: wildcardSeg}, s[2:], nil
+	case strings.HasPrefix(s, `"`):
+		key, rest, err := unquote(s)
+		if err != nil {
+			return segment{}, "", err
+		}
+		if !strings.HasPrefix(rest, "]") {
+			return segment{}, "", fmt.Errorf("missing ]")
+		}
+		return segment{kind: keySeg, key: key}, rest[1:], nil
+	case strings.HasPrefix(s, "?"):
+		end := closeBracket(s)
+		if end < 0 {
+			return segment{}, "", fmt.Errorf("missing ]")
+		}
+		f, err := parseFilter(s[1:end])
+		if err != nil {
+			return segment{}, "", err
+		}
+		return segment{kind: filterSeg, filter: f}, s[end+1:], nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return segment{}, "", fmt.Errorf("missing ]")
+	}
+	idx, err := strconv.Atoi(strings.TrimSpace(s[:end]))
+	if err != nil {
+		return segment{}, "", fmt.Errorf("invalid index %q", s[:end])
+	}
+	return segment{kind: indexSeg, index: idx}, s[end+1:], nil
+}
+
+// closeBracket 查找与开头匹配的 ], 跳过字符串字面量与过滤字段中嵌套的 [...]
+func closeBracket(s string) int {
+	quoted := false
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted:
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				quoted = false
+			}
+		case s[i] == '"':
+			quoted = true
+		case s[i] == '[':
+			depth++
+		case s[i] == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func unquote(s string) (string, string, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			str, err := strconv.Unquote(s[:i+1])
+			return str, s[i+1:], err
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+type filter struct {
+	raw   string
+	field *Path
+	op    string
+	value interface{}
+}
+
+var filterOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// findOp 查找第一个不在字符串字面量中的比较运算符, 两个字符的运算符优先
+func findOp(s string) (int, string) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted:
+			for _, op := range filterOps {
+				if strings.HasPrefix(s[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func parseFilter(s string) (*filter, error) {
+	f := &filter{raw: s}
+	field := s
+	if n, op := findOp(s); n >= 0 {
+		f.op = op
+		field = s[:n]
+		value, err := parseLiteral(strings.TrimSpace(s[n+len(op):]))
+		if err != nil {
+			return nil, err
+		}
+		f.value = value
+	}
+	field = strings.TrimPrefix(strings.TrimSpace(field), "@.")
+	if field == "" {
+		return nil, fmt.Errorf("empty filter field")
+	}
+	path, err := ParsePath(field)
+	if err != nil {
+		return nil, err
+	}
+	f.field = path
+	return f, nil
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	switch s {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		str, rest, err := unquote(s)
+		if err == nil && rest != "" {
+			err = fmt.Errorf("invalid literal %s", s)
+		}
+		return str, err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid literal %s", s)
+	}
+	return f, nil
+}
+
+func (f *filter) match(elem interface{}) bool {
+	var got []interface{}
+	if err := f.field.eval(elem, 0, "", &got, false); err != nil || len(got) == 0 {
+		return false
+	}
+	value := got[0]
+	if f.op == "" {
+		return value != nil
+	}
+	if a, ok := toFloat(value); ok {
+		b, ok := toFloat(f.value)
+		if !ok {
+			return f.op == "!="
+		}
+		return compare(f.op, a < b, a == b)
+	}
+	if a, ok := value.(string); ok {
+		b, ok := f.value.(string)
+		if !ok {
+			return f.op == "!="
+		}
+		return compare(f.op, a < b, a == b)
+	}
+	switch f.op {
+	case "==":
+		return value == f.value
+	case "!=":
+		return value != f.value
+	}
+	return false
+}
+
+func compare(op string, less, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+// toFloat 统一各种数值类型以便比较, 包括 Set 写入的整数、float32 与 UseNumber 解码的 json.Number
+func toFloat(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case nil:
+		return 0, false
+	}
+	rv := reflect.ValueOf(in)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// unwrap 取出容器的底层 map/slice
+func unwrap(in interface{}) interface{} {
+	switch v := in.(type) {
+	case *Object:
+		return v.content
+	case *Array:
+		return v.content
+	}
+	return in
+}
+
+// wrap 将底层 map/slice 包装为 *Object/*Array
+func wrap(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		return &Object{content: v}
+	case []interface{}:
+		return &Array{content: v}
+	}
+	return in
+}
+
+func typeName(in interface{}) string {
+	switch unwrap(in).(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", in)
+}
+
+func (p *Path) fail(at string, err error, want string, got interface{}) error {
+	e := &PathError{Path: p.raw, At: strings.TrimPrefix(at, "."), Err: err}
+	if err == ErrType {
+		e.Want, e.Got = want, typeName(got)
+	}
+	return e
+}
+
+// eval 从第 n 段开始求值, strict 为 false 时(通配/过滤之后)跳过不满足的分支
+func (p *Path) eval(cur interface{}, n int, at string, out *[]interface{}, strict bool) error {
+	if n == len(p.segs) {
+		*out = append(*out, wrap(unwrap(cur)))
+		return nil
+	}
+	seg := p.segs[n]
+	next := at + seg.String()
+	cur = unwrap(cur)
+
+	switch seg.kind {
+	case keySeg:
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			if strict {
+				return p.fail(at, ErrType, "object", cur)
+			}
+			return nil
+		}
+		value, ok := m[seg.key]
+		if !ok {
+			if strict {
+				return p.fail(next, ErrMissing, "", nil)
+			}
+			return nil
+		}
+		return p.eval(value, n+1, next, out, strict)
+	case indexSeg:
+		arr, ok := cur.([]interface{})
+		if !ok {
+			if strict {
+				return p.fail(at, ErrType, "array", cur)
+			}
+			return nil
+		}
+		idx := seg.index
+		if idx < 0 {
+			idx += len(arr)
+		}
+		if idx < 0 || idx >= len(arr) {
+			if strict {
+				return p.fail(next, ErrMissing, "", nil)
+			}
+			return nil
+		}
+		return p.eval(arr[idx], n+1, next, out, strict)
+	default:
+		var elems []interface{}
+		switch v := cur.(type) {
+		case []interface{}:
+			elems = v
+		case map[string]interface{}:
+			for _, key := range sortedKeys(v) {
+				elems = append(elems, v[key])
+			}
+		default:
+			if strict {
+				return p.fail(at, ErrType, "object or array", cur)
+			}
+			return nil
+		}
+		for _, elem := range elems {
+			if seg.kind == filterSeg && !seg.filter.match(elem) {
+				continue
+			}
+			if err := p.eval(elem, n+1, next, out, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Query 返回路径匹配的所有值, 对象与数组包装为 *Object/*Array
+func (p *Path) Query(root interface{}) ([]interface{}, error) {
+	out := make([]interface{}, 0)
+	if err := p.eval(root, 0, "", &out, true); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Find 返回路径匹配的第一个值, 没有匹配时返回 ErrMissing
+func (p *Path) Find(root interface{}) (interface{}, error) {
+	out, err := p.Query(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, p.fail(p.raw, ErrMissing, "", nil)
+	}
+	return out[0], nil
+}
+
+// Set 设置路径上的值, 缺少的中间对象会被创建; 下标等于数组长度时追加. 路径中不能有通配与过滤
+func (p *Path) Set(root interface{}, value interface{}) error {
+	if len(p.segs) == 0 {
+		return fmt.Errorf("json path %s: cannot set root", p.raw)
+	}
+	_, err := p.set(root, 0, "", value)
+	return err
+}
+
+func (p *Path) set(cur interface{}, n int, at string, value interface{}) (interface{}, error) {
+	if n == len(p.segs) {
+		return value, nil
+	}
+	seg := p.segs[n]
+	next := at + seg.String()
+
+	switch seg.kind {
+	case keySeg:
+		var m map[string]interface{}
+		switch v := cur.(type) {
+		case nil:
+			m = make(map[string]interface{})
+			cur = m
+		case map[string]interface{}:
+			m = v
+		case *Object:
+			m = v.content
+		default:
+			return nil, p.fail(at, ErrType, "object", cur)
+		}
+		child, err := p.set(m[seg.key], n+1, next, value)
+		if err != nil {
+			return nil, err
+		}
+		m[seg.key] = child
+		return cur, nil
+	case indexSeg:
+		var arr []interface{}
+		switch v := cur.(type) {
+		case nil:
+		case []interface{}:
+			arr = v
+		case *Array:
+			arr = v.content
+		default:
+			return nil, p.fail(at, ErrType, "array", cur)
+		}
+		idx := seg.index
+		if idx < 0 {
+			idx += len(arr)
+		}
+		if idx == len(arr) {
+			arr = append(arr, nil)
+		}
+		if idx < 0 || idx >= len(arr) {
+			return nil, p.fail(next, ErrMissing, "", nil)
+		}
+		child, err := p.set(arr[idx], n+1, next, value)
+		if err != nil {
+			return nil, err
+		}
+		arr[idx] = child
+		if a, ok := cur.(*Array); ok {
+			a.content = arr
+			return a, nil
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("json path %s: cannot set through %s", p.raw, seg)
+}
+
+func (o *Object) Query(path string) ([]interface{}, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(o)
+}
+
+func (o *Object) Find(path string) (interface{}, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(o)
+}
+
+func (o *Object) SetPath(path string, value interface{}) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return p.Set(o, value)
+}
+
+func (o *Object) FindInt(path string) (int, error) {
+	return Lookup[int](o, path)
+}
+
+func (o *Object) FindString(path string) (string, error) {
+	return Lookup[string](o, path)
+}
+
+func (o *Object) FindBool(path string) (bool, error) {
+	return Lookup[bool](o, path)
+}
+
+func (o *Object) FindFloat(path string) (float64, error) {
+	return Lookup[float64](o, path)
+}
+
+func (o *Object) FindObject(path string) (*Object, error) {
+	return Lookup[*Object](o, path)
+}
+
+func (o *Object) FindArray(path string) (*Array, error) {
+	return Lookup[*Array](o, path)
+}
+
+func (a *Array) Query(path string) ([]interface{}, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(a)
+}
+
+func (a *Array) Find(path string) (interface{}, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(a)
+}
+
+func (a *Array) SetPath(path string, value interface{}) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	return p.Set(a, value)
+}
+
+func (a *Array) FindInt(path string) (int, error) {
+	return Lookup[int](a, path)
+}
+
+func (a *Array) FindString(path string) (string, error) {
+	return Lookup[string](a, path)
+}
+
+func (a *Array) FindBool(path string) (bool, error) {
+	return Lookup[bool](a, path)
+}
+
+func (a *Array) FindFloat(path string) (float64, error) {
+	return Lookup[float64](a, path)
+}
+
+func (a *Array) FindObject(path string) (*Object, error) {
+	return Lookup[*Object](a, path)
+}
+
+func (a *Array) FindArray(path string) (*Array, error) {
+	return Lookup[*Array](a, path)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
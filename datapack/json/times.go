@@ -0,0 +1,128 @@
+package json
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/datapack/format"
+	"github.com/youngpto/funs_tool/times"
+	"strconv"
+	"time"
+)
+
+// TextKeys 返回 *Object 或 *Array 元素中声明为本地化文本(title:text)的顶层键, 需在 ConvTimes 之前调用.
+// 只有顶层键会生成文本类型, 嵌套的对象中声明文本时返回错误
+func TextKeys(in interface{}) ([]string, error) {
+	var objects []map[string]interface{}
+	switch v := in.(type) {
+	case *Object:
+		objects = append(objects, v.content)
+	case *Array:
+		for i, elem := range v.content {
+			if m, ok := elem.(map[string]interface{}); ok {
+				objects = append(objects, m)
+			} else if err := checkNestedText(elem, fmt.Sprintf("[%d]", i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, m := range objects {
+		for key, value := range m {
+			name, typ := format.SplitKeyType(key)
+			if err := checkNestedText(value, name); err != nil {
+				return nil, err
+			}
+			if _, ok := seen[name]; ok || typ != format.TextKeyType {
+				continue
+			}
+			seen[name] = struct{}{}
+			keys = append(keys, name)
+		}
+	}
+	return keys, nil
+}
+
+func checkNestedText(in interface{}, path string) error {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			name, typ := format.SplitKeyType(key)
+			if typ == format.TextKeyType {
+				return fmt.Errorf("%s.%s: text is only supported on top-level keys", path, key)
+			}
+			if err := checkNestedText(value, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			if err := checkNestedText(value, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ConvTimes 将 *Object 或 *Array 中声明了类型的键(start:time、cd:duration)的值转换为 time.Time/time.Duration,
+// 字符串与数字形式(20261001、3600)都会被转换, 声明会从键名中去掉. 未声明的键保持原值, "5m" 等字符串不会被当作时长.
+func ConvTimes(in interface{}) error {
+	switch v := in.(type) {
+	case *Object:
+		content, err := convTimes(v.content, "")
+		if err != nil {
+			return err
+		}
+		v.content = content.(map[string]interface{})
+	case *Array:
+		content, err := convTimes(v.content, "")
+		if err != nil {
+			return err
+		}
+		v.content = content.([]interface{})
+	}
+	return nil
+}
+
+func convTimes(in interface{}, declared string) (interface{}, error) {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			name, typ := format.SplitKeyType(key)
+			conv, err := convTimes(value, typ)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			out[name] = conv
+		}
+		return out, nil
+	case []interface{}:
+		for i, value := range v {
+			conv, err := convTimes(value, declared)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %v", i, err)
+			}
+			v[i] = conv
+		}
+		return v, nil
+	case string:
+		switch declared {
+		case format.TimeKeyType, format.DateKeyType:
+			return times.ParseTime(v)
+		case format.DurationKeyType:
+			return times.ParseDuration(v)
+		}
+		return v, nil
+	case float64:
+		switch declared {
+		case format.TimeKeyType, format.DateKeyType:
+			return times.ParseTime(strconv.FormatFloat(v, 'f', -1, 64))
+		case format.DurationKeyType:
+			return time.Duration(v * float64(time.Second)), nil
+		}
+		return v, nil
+	}
+	return in, nil
+}
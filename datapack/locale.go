@@ -0,0 +1,113 @@
+package datapack
+
+import (
+	stdjson "encoding/json"
+	"fmt"
+	"github.com/youngpto/funs_tool/algorithm"
+	"github.com/youngpto/funs_tool/datapack/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TextType 本地化文本字段在生成代码中的类型
+const TextType = "fs_i18n.Text"
+
+// extractTexts 将声明为文本的字段替换为文本键, 返回 文本键 -> 原文
+//
+// 文本键格式: 行数据为 结构名.ID.字段, 对象为 结构名.分片名.字段, 数组为 结构名.分片名[下标].字段
+func extractTexts(root *inode) map[string]string {
+	texts := make(map[string]string)
+	algorithm.DFS(root, func(pop *inode) []*inode {
+		if !pop.isdir {
+			pop.extractTexts(texts)
+		}
+		return pop.nodes
+	})
+	return texts
+}
+
+func (i *inode) extractTexts(texts map[string]string) {
+	table := i.load()
+	if table.Elem != "" {
+		return
+	}
+	var fields []string
+	for _, field := range table.Fields {
+		if field.Text {
+			fields = append(fields, field.Name)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	extract := func(prefix string, record *json.Object) {
+		for _, field := range fields {
+			value := record.Get(field)
+			if value == nil {
+				continue
+			}
+			key := fmt.Sprintf("%s.%s", prefix, field)
+			texts[key] = fmt.Sprint(value)
+			record.Set(field, key)
+		}
+	}
+
+	if table.Layout == RowsLayout {
+		rows, _ := table.Data.(map[int]*json.Object)
+		for id, record := range rows {
+			extract(fmt.Sprintf("%s.%d", i.structname, id), record)
+		}
+		return
+	}
+	for _, shard := range i.shards {
+		switch data := shard.load().Data.(type) {
+		case *json.Object:
+			extract(fmt.Sprintf("%s.%s", i.structname, shard.name), data)
+		case *json.Array:
+			for idx := 0; idx < data.Len(); idx++ {
+				if record, ok := data.GetObject(idx); ok {
+					extract(fmt.Sprintf("%s.%s[%d]", i.structname, shard.name, idx), record)
+				}
+			}
+		}
+	}
+}
+
+// stringTables 生成各语言的字符串表. 基础语言使用原文, 其余语言保留 dir 下已有的翻译,
+// 缺少的键写为空字符串并按语言返回
+func stringTables(texts map[string]string, o *options) (map[string][]byte, map[string][]string, error) {
+	files := make(map[string][]byte)
+	missing := make(map[string][]string)
+	for _, locale := range append([]string{o.baseLocale}, o.locales...) {
+		path := filepath.Join(o.localeDir, locale+".json")
+		table := make(map[string]string, len(texts))
+		if locale == o.baseLocale {
+			for key, text := range texts {
+				table[key] = text
+			}
+		} else {
+			exist := make(map[string]string)
+			if data, err := os.ReadFile(path); err == nil {
+				if err = stdjson.Unmarshal(data, &exist); err != nil {
+					return nil, nil, fmt.Errorf("load %s: %v", path, err)
+				}
+			}
+			for key := range texts {
+				table[key] = exist[key]
+				if exist[key] == "" {
+					missing[locale] = append(missing[locale], key)
+				}
+			}
+			sort.Strings(missing[locale])
+		}
+
+		data, err := stdjson.MarshalIndent(table, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		files[path] = data
+	}
+	return files, missing, nil
+}
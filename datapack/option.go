@@ -0,0 +1,120 @@
+package datapack
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/datapack/bundle"
+	"strings"
+)
+
+type Option func(opts *options)
+
+type options struct {
+	check       []string
+	bundle      bool
+	compression bundle.Compression
+	revision    string
+	embed       bool
+	pkg         string
+	formats     map[string]struct{}
+	verbose     int
+	localeDir   string
+	baseLocale  string
+	locales     []string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		pkg:     "conf",
+		verbose: 2,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *options) logf(level int, format string, args ...interface{}) {
+	if o.verbose >= level {
+		fmt.Printf(format, args...)
+	}
+}
+
+func (o *options) parser(ext string) (SourceParser, bool) {
+	if o.formats != nil {
+		if _, ok := o.formats[normalizeExt(ext)]; !ok {
+			return nil, false
+		}
+	}
+	return GetParser(ext)
+}
+
+// WithPackage 生成代码的包名, 默认 conf
+func WithPackage(pkg string) Option {
+	return func(opts *options) {
+		opts.pkg = pkg
+	}
+}
+
+// WithFormats 只处理指定扩展名的文件, 如 "csv", ".json", 默认处理全部已注册的格式
+func WithFormats(exts ...string) Option {
+	return func(opts *options) {
+		opts.formats = make(map[string]struct{}, len(exts))
+		for _, ext := range exts {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				opts.formats[normalizeExt(ext)] = struct{}{}
+			}
+		}
+	}
+}
+
+// WithVerbose 输出级别, 0 不输出, 1 输出耗时与跳过的文件, 2 输出访问的每个文件(默认)
+func WithVerbose(level int) Option {
+	return func(opts *options) {
+		opts.verbose = level
+	}
+}
+
+// WithCheck 打包完成后执行校验命令, 打包文件路径作为最后一个参数传入, 命令失败时 Conf2Src 返回错误
+//
+//	datapack.WithCheck("go", "run", "./conf/cmd/check")
+func WithCheck(name string, args ...string) Option {
+	return func(opts *options) {
+		opts.check = append([]string{name}, args...)
+	}
+}
+
+// WithBundle 以带校验头的 bundle 格式输出打包文件, 生成的 Load 会在解码前校验
+func WithBundle(compression bundle.Compression) Option {
+	return func(opts *options) {
+		opts.bundle = true
+		opts.compression = compression
+	}
+}
+
+// WithRevision 写入 bundle 的源码版本, 如 git rev-parse HEAD 的结果
+func WithRevision(revision string) Option {
+	return func(opts *options) {
+		opts.revision = revision
+	}
+}
+
+// WithEmbed 通过 go:embed 将打包文件嵌入生成的包, 打包文件须位于生成文件所在目录或其子目录
+func WithEmbed() Option {
+	return func(opts *options) {
+		opts.embed = true
+	}
+}
+
+// WithLocalize 提取声明为文本的列(title:text)到 dir 下各语言的字符串表 <locale>.json, 生成的字段类型为 fs_i18n.Text.
+// base 为配置中内联文本的语言, 其余语言保留已有翻译, 缺少的翻译写为空字符串并按语言报告.
+func WithLocalize(dir string, base string, locales ...string) Option {
+	return func(opts *options) {
+		opts.localeDir = dir
+		opts.baseLocale = base
+		opts.locales = locales
+	}
+}
+
+func (o *options) localize() bool {
+	return o.localeDir != ""
+}
@@ -0,0 +1,161 @@
+package datapack
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"fmt"
+	"github.com/youngpto/funs_tool/coll/sets/hashset"
+	"github.com/youngpto/funs_tool/datapack/bundle"
+	"github.com/youngpto/funs_tool/datapack/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TableInfo 发现的表
+type TableInfo struct {
+	Path   string // 相对配置根目录的路径
+	Struct string // 生成的结构名
+	Type   string // 在上级结构中的字段类型
+	Table  *Table
+}
+
+// Output 生成结果
+type Output struct {
+	Source  []byte // 格式化后的 go 代码
+	Blob    []byte // 打包数据
+	Tables  []TableInfo
+	Strings map[string][]byte   // 字符串表文件路径 -> 内容, 开启 WithLocalize 时生成
+	Missing map[string][]string // 语言 -> 缺少翻译的文本键
+}
+
+// Generate 解析配置目录, 在内存中生成代码与打包数据, 不写文件.
+// genFile 与 msgpackFile 仅用于计算 go:embed 的相对路径.
+func Generate(rootPath string, genFile string, msgpackFile string, opts ...Option) (out *Output, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	o := newOptions(opts)
+	prefix = filepath.ToSlash(filepath.Join(rootPath, ""))
+
+	var embed string
+	if o.embed {
+		rel, err := filepath.Rel(filepath.Dir(genFile), msgpackFile)
+		if err != nil || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+			return nil, fmt.Errorf("embed %s: must be under %s", msgpackFile, filepath.Dir(genFile))
+		}
+		embed = filepath.ToSlash(rel)
+	}
+
+	root := &inode{
+		name:       "config",
+		isdir:      true,
+		path:       rootPath,
+		structname: "gameConfig",
+		binary:     json.NewObject(),
+	}
+	exist := hashset.New[string]()
+	visit(rootPath, root, exist, o)
+
+	var strs map[string][]byte
+	var missing map[string][]string
+	if o.localize() {
+		strs, missing, err = stringTables(extractTexts(root), o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	src, schema, tables, err := conf2go(root, o, embed)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := stdjson.Marshal(root.binary)
+	if err != nil {
+		return nil, err
+	}
+	if o.bundle {
+		blob, err = bundle.Encode(bundle.Header{
+			Compression: o.compression,
+			Schema:      schema,
+			BuildTime:   time.Now(),
+			Revision:    o.revision,
+		}, blob)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out = &Output{
+		Source:  src,
+		Blob:    blob,
+		Tables:  make([]TableInfo, 0, len(tables)),
+		Strings: strs,
+		Missing: missing,
+	}
+	for _, table := range tables {
+		out.Tables = append(out.Tables, TableInfo{
+			Path:   table.Path,
+			Struct: table.Name,
+			Type:   table.Type,
+			Table:  table.table,
+		})
+	}
+	return out, nil
+}
+
+// Write 写入生成的代码、打包数据与字符串表
+func (out *Output) Write(genFile string, msgpackFile string) error {
+	if err := ioutil.WriteFile(genFile, out.Source, 0644); err != nil {
+		return err
+	}
+	for path, data := range out.Strings {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(msgpackFile, out.Blob, 0644)
+}
+
+// Stale 返回与生成结果不一致的文件, bundle 只比较结构哈希与数据, 忽略构建时间与版本
+func (out *Output) Stale(genFile string, msgpackFile string) []string {
+	var stale []string
+	if src, err := os.ReadFile(genFile); err != nil || !bytes.Equal(src, out.Source) {
+		stale = append(stale, genFile)
+	}
+	if blob, err := os.ReadFile(msgpackFile); err != nil || !sameBlob(blob, out.Blob) {
+		stale = append(stale, msgpackFile)
+	}
+	var paths []string
+	for path, data := range out.Strings {
+		if exist, err := os.ReadFile(path); err != nil || !bytes.Equal(exist, data) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return append(stale, paths...)
+}
+
+func sameBlob(a, b []byte) bool {
+	if !bundle.IsBundle(a) || !bundle.IsBundle(b) {
+		return bytes.Equal(a, b)
+	}
+	ha, pa, err := bundle.Decode(a)
+	if err != nil {
+		return false
+	}
+	hb, pb, err := bundle.Decode(b)
+	if err != nil {
+		return false
+	}
+	return ha.Schema == hb.Schema && ha.Compression == hb.Compression && bytes.Equal(pa, pb)
+}
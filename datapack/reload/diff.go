@@ -0,0 +1,134 @@
+package reload
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change 一张表的变更, 元素为行键
+type Change struct {
+	Added   []interface{}
+	Removed []interface{}
+	Changed []interface{}
+}
+
+// ChangeSet 按表路径(json 标签以 / 连接, 如 item/weapon)划分的变更, 没有变化的表不会出现
+type ChangeSet map[string]*Change
+
+// Has 表是否有变更
+func (cs ChangeSet) Has(table string) bool {
+	_, ok := cs[table]
+	return ok
+}
+
+// Diff 比较两份配置, 结构体字段视为目录, map 字段视为表
+func Diff(old, cur interface{}) ChangeSet {
+	changes := make(ChangeSet)
+	diffValue(changes, "", reflect.ValueOf(old), reflect.ValueOf(cur))
+	return changes
+}
+
+func diffValue(changes ChangeSet, path string, old, cur reflect.Value) {
+	old, cur = indirect(old), indirect(cur)
+	if !old.IsValid() && !cur.IsValid() {
+		return
+	}
+
+	valid := old
+	if !valid.IsValid() {
+		valid = cur
+	}
+	typ := valid.Type()
+	// 结构体指针从 nil 变为非 nil(或相反)整体记为一次变更
+	if typ.Kind() == reflect.Struct && old.IsValid() != cur.IsValid() {
+		changes[path] = &Change{}
+		return
+	}
+	switch typ.Kind() {
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			var o, c reflect.Value
+			if old.IsValid() {
+				o = old.Field(i)
+			}
+			if cur.IsValid() {
+				c = cur.Field(i)
+			}
+			diffValue(changes, join(path, field), o, c)
+		}
+	case reflect.Map:
+		if change := diffMap(old, cur); change != nil {
+			changes[path] = change
+		}
+	default:
+		if !old.IsValid() || !cur.IsValid() || !reflect.DeepEqual(old.Interface(), cur.Interface()) {
+			changes[path] = &Change{}
+		}
+	}
+}
+
+func diffMap(old, cur reflect.Value) *Change {
+	change := &Change{}
+	if old.IsValid() {
+		for _, key := range old.MapKeys() {
+			if !cur.IsValid() || !cur.MapIndex(key).IsValid() {
+				change.Removed = append(change.Removed, key.Interface())
+			} else if !reflect.DeepEqual(old.MapIndex(key).Interface(), cur.MapIndex(key).Interface()) {
+				change.Changed = append(change.Changed, key.Interface())
+			}
+		}
+	}
+	if cur.IsValid() {
+		for _, key := range cur.MapKeys() {
+			if !old.IsValid() || !old.MapIndex(key).IsValid() {
+				change.Added = append(change.Added, key.Interface())
+			}
+		}
+	}
+	if len(change.Added)+len(change.Removed)+len(change.Changed) == 0 {
+		return nil
+	}
+	sortKeys(change.Added)
+	sortKeys(change.Removed)
+	sortKeys(change.Changed)
+	return change
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func join(path string, field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		name = field.Name
+	}
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
+
+func sortKeys(keys []interface{}) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := reflect.ValueOf(keys[i]), reflect.ValueOf(keys[j])
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.String:
+			return a.String() < b.String()
+		}
+		return false
+	})
+}
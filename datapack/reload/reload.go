@@ -0,0 +1,211 @@
+package reload
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/youngpto/funs_tool/async"
+	"github.com/youngpto/funs_tool/logger"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	reloader, err := conf.NewReloader("./conf/msgpack.json", reload.WithInterval(5*time.Second))
+	reloader.Subscribe(func(old, cur *conf.Config, changes reload.ChangeSet) {
+		if change, ok := changes["item/weapon"]; ok {
+			...
+		}
+	})
+	reloader.Start()
+	cfg := reloader.Current()
+*/
+
+// Loader 解码并校验打包数据, 通常为生成代码中的 Load
+type Loader[T any] func(data []byte) (T, error)
+
+// Subscriber 配置切换后的回调, changes 为按表划分的变更
+type Subscriber[T any] func(old, cur T, changes ChangeSet)
+
+type Option func(opts *options)
+
+type options struct {
+	interval time.Duration
+	onError  func(err error)
+}
+
+// WithInterval 轮询打包文件的间隔, 默认 3s
+func WithInterval(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.interval = interval
+	}
+}
+
+// WithErrorHandler 重载失败时的回调, 默认写日志
+func WithErrorHandler(f func(err error)) Option {
+	return func(opts *options) {
+		opts.onError = f
+	}
+}
+
+type snapshot[T any] struct {
+	value T
+	sum   [32]byte
+}
+
+// Reloader 轮询打包文件, 在旁路解码校验成功后原子替换当前配置, 失败时保留旧配置
+type Reloader[T any] struct {
+	path string
+	load Loader[T]
+	opts *options
+
+	current atomic.Value
+	modTime time.Time
+	size    int64
+
+	mu       sync.Mutex
+	subMu    sync.RWMutex
+	subs     []Subscriber[T]
+	stopChan chan struct{}
+}
+
+// New 创建并立即加载一次, 首次加载失败时返回错误
+func New[T any](path string, load Loader[T], opts ...Option) (*Reloader[T], error) {
+	o := &options{
+		interval: 3 * time.Second,
+		onError: func(err error) {
+			logger.Error("reload %v", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	r := &Reloader[T]{
+		path: path,
+		load: load,
+		opts: o,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Current 当前生效的配置
+func (r *Reloader[T]) Current() T {
+	return r.current.Load().(*snapshot[T]).value
+}
+
+// Subscribe 注册配置切换回调
+func (r *Reloader[T]) Subscribe(f Subscriber[T]) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	r.subs = append(r.subs, f)
+}
+
+// Reload 立即重新加载, 内容未变化时返回 false. 订阅者在释放锁之后回调, 回调中可以再调用 Reload/Stop
+func (r *Reloader[T]) Reload() (bool, error) {
+	old, value, changed, err := r.swap()
+	if err != nil || !changed || old == nil {
+		return changed, err
+	}
+
+	changes := Diff(old.value, value)
+	r.subMu.RLock()
+	subs := make([]Subscriber[T], len(r.subs))
+	copy(subs, r.subs)
+	r.subMu.RUnlock()
+	for _, sub := range subs {
+		r.notify(sub, old.value, value, changes)
+	}
+	return true, nil
+}
+
+// swap 读取并解码打包文件, 成功时替换当前配置, 返回替换前的快照
+func (r *Reloader[T]) swap() (old *snapshot[T], value T, changed bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return
+	}
+	r.modTime, r.size = info.ModTime(), info.Size()
+
+	sum := sha256.Sum256(data)
+	old, _ = r.current.Load().(*snapshot[T])
+	if old != nil && bytes.Equal(old.sum[:], sum[:]) {
+		return old, value, false, nil
+	}
+
+	if value, err = r.load(data); err != nil {
+		return old, value, false, fmt.Errorf("load %s: %w", r.path, err)
+	}
+	r.current.Store(&snapshot[T]{value: value, sum: sum})
+	return old, value, true, nil
+}
+
+func (r *Reloader[T]) notify(sub Subscriber[T], old, cur T, changes ChangeSet) {
+	defer func() {
+		if err := recover(); err != nil {
+			r.opts.onError(fmt.Errorf("subscriber %s: %v", r.path, err))
+		}
+	}()
+	sub(old, cur, changes)
+}
+
+// Start 开始轮询, 文件修改时间或大小变化时重新加载
+func (r *Reloader[T]) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan != nil {
+		return
+	}
+	r.stopChan = make(chan struct{})
+	async.Go(r.watch, r.stopChan)
+}
+
+// Stop 停止轮询
+func (r *Reloader[T]) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopChan != nil {
+		close(r.stopChan)
+		r.stopChan = nil
+	}
+}
+
+func (r *Reloader[T]) watch(stop chan struct{}) {
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !r.modified() {
+				continue
+			}
+			if _, err := r.Reload(); err != nil {
+				r.opts.onError(err)
+			}
+		}
+	}
+}
+
+func (r *Reloader[T]) modified() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size
+}
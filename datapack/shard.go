@@ -0,0 +1,210 @@
+package datapack
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/coll/sets/hashset"
+	"github.com/youngpto/funs_tool/datapack/format"
+	"github.com/youngpto/funs_tool/datapack/json"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+分片表
+
+同一目录下的 Name.json、Name_1.json、Name_2.json ... 是表 Name 的分片, 生成一个字段
+Name map[string]X, 以分片文件名为键. 规则如下:
+
+  - 各分片的组织方式(对象/数组)与元素类型必须一致
+  - 各分片的字段取并集, 同名字段类型必须一致, int 与 float64 合并为 float64
+  - 数组分片中记录带 id 字段时, 跨分片重复的 id 视为错误
+  - 分片表的记录结构附加 ShardOrigin 字段(数据键 _shard), 值为来源分片名
+  - 分片表名不能与同目录下的其他表或目录重名
+
+以 ID 索引的行数据(如 csv)不参与分片, 带数字后缀的文件仍是独立的表.
+*/
+
+// ShardKey 记录中标记来源分片的键
+const ShardKey = "_shard"
+
+var shardPattern = regexp.MustCompile(`^(.+)_(\d+)$`)
+
+// splitShard 拆分文件名中的分片序号, 非分片文件返回 -1
+func splitShard(name string) (string, int) {
+	m := shardPattern.FindStringSubmatch(name)
+	if m == nil {
+		return name, -1
+	}
+	no, err := strconv.Atoi(m[2])
+	if err != nil {
+		return name, -1
+	}
+	return m[1], no
+}
+
+// groupShards 将目录下的分片文件合并为表节点
+func groupShards(parent *inode, exist *hashset.Set[string]) {
+	nodes := make([]*inode, 0, len(parent.nodes))
+	groups := make(map[string]*inode)
+	for _, node := range parent.nodes {
+		if node.isdir || node.load().Layout == RowsLayout {
+			nodes = append(nodes, node)
+			continue
+		}
+
+		base, _ := splitShard(node.name)
+		key := base + node.ext
+		group, ok := groups[key]
+		if !ok {
+			group = &inode{
+				name:        base,
+				ext:         node.ext,
+				path:        node.path,
+				prev:        parent,
+				structname:  strings.TrimSuffix(node.structname, node.name[len(base):]),
+				variatename: format.Title(base),
+				binary:      json.NewObject(),
+			}
+			groups[key] = group
+			nodes = append(nodes, group)
+		}
+		group.shards = append(group.shards, node)
+	}
+
+	names := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		if node.shards != nil {
+			sort.SliceStable(node.shards, func(a, b int) bool {
+				_, na := splitShard(node.shards[a].name)
+				_, nb := splitShard(node.shards[b].name)
+				return na < nb
+			})
+			if node.sharded() {
+				node.path = filepath.Join(filepath.Dir(node.path), node.name+"_*"+node.ext)
+				if _, no := splitShard(node.shards[0].name); no >= 0 {
+					if exist.Contains(node.structname) {
+						panic(fmt.Sprintf("filename %s is exist \n", node.structname))
+					}
+					exist.Add(node.structname)
+				}
+			}
+		}
+
+		if other, ok := names[node.variatename]; ok {
+			panic(fmt.Sprintf("table %s conflicts with %s", prettycomment(node.path), other))
+		}
+		names[node.variatename] = prettycomment(node.path)
+	}
+	parent.nodes = nodes
+}
+
+func (i *inode) sharded() bool {
+	if len(i.shards) > 1 {
+		return true
+	}
+	_, no := splitShard(i.shards[0].name)
+	return no >= 0
+}
+
+// mergeShards 统一各分片的结构, 并将分片数据按文件名挂到 binary 上
+func (i *inode) mergeShards() (*Table, error) {
+	sharded := i.sharded()
+	merged := &Table{}
+	index := make(map[string]int)
+	ids := make(map[interface{}]string)
+
+	for n, shard := range i.shards {
+		table := shard.load()
+		if n == 0 {
+			merged.Layout = table.Layout
+			merged.Elem = table.Elem
+		} else if table.Layout != merged.Layout || table.Elem != merged.Elem {
+			return nil, fmt.Errorf("shard %s layout not same as %s", prettycomment(shard.path), prettycomment(i.shards[0].path))
+		}
+
+		for _, field := range table.Fields {
+			idx, ok := index[field.Name]
+			if !ok {
+				index[field.Name] = len(merged.Fields)
+				merged.Fields = append(merged.Fields, field)
+				continue
+			}
+			typ, err := unifyType(merged.Fields[idx].Type, field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("shard %s field %s: %v", prettycomment(shard.path), field.Name, err)
+			}
+			merged.Fields[idx].Type = typ
+			merged.Fields[idx].Text = merged.Fields[idx].Text || field.Text
+		}
+
+		if sharded && merged.Elem == "" {
+			switch data := table.Data.(type) {
+			case *json.Object:
+				data.Set(ShardKey, shard.name)
+			case *json.Array:
+				for idx := 0; idx < data.Len(); idx++ {
+					record, ok := data.GetObject(idx)
+					if !ok {
+						continue
+					}
+					record.Set(ShardKey, shard.name)
+					id, ok := recordID(record)
+					if !ok {
+						continue
+					}
+					if prev, dup := ids[id]; dup {
+						return nil, fmt.Errorf("id %v is duplicated in shard %s and %s", id, prev, shard.name)
+					}
+					ids[id] = shard.name
+				}
+			}
+		}
+		i.binary.Set(shard.name, table.Data)
+	}
+
+	if sharded && merged.Elem == "" {
+		merged.Fields = append(merged.Fields, Field{
+			Name:    ShardKey,
+			Type:    "string",
+			Comment: "来源分片",
+		})
+	}
+	return merged, nil
+}
+
+func unifyType(a, b string) (string, error) {
+	if a == b {
+		return a, nil
+	}
+	numeric := map[string]bool{"int": true, "float64": true}
+	if numeric[a] && numeric[b] {
+		return "float64", nil
+	}
+	if a == "interface{}" {
+		return b, nil
+	}
+	if b == "interface{}" {
+		return a, nil
+	}
+	return "", fmt.Errorf("type %s not same as %s", b, a)
+}
+
+func recordID(record *json.Object) (interface{}, bool) {
+	for _, key := range []string{"id", "ID", "Id"} {
+		if v := record.Get(key); v != nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// fieldName 数据键对应的 go 字段名
+func fieldName(key string) string {
+	if key == ShardKey {
+		return "ShardOrigin"
+	}
+	return format.Title(key)
+}
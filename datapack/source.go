@@ -0,0 +1,178 @@
+package datapack
+
+import (
+	"fmt"
+	"github.com/youngpto/funs_tool/coll_utils"
+	"github.com/youngpto/funs_tool/datapack/csv"
+	"github.com/youngpto/funs_tool/datapack/json"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Layout 配置数据的组织方式, 决定生成的字段类型
+type Layout int
+
+const (
+	RowsLayout   Layout = iota // 以 ID 索引的多行记录, 生成 map[int]*T
+	ObjectLayout               // 单个对象, 生成 map[string]*T
+	ArrayLayout                // 数组, 生成 map[string][]*T 或 map[string][]Elem
+)
+
+// Field 记录结构中的一个字段
+type Field struct {
+	Name    string // 数据中的键名
+	Type    string // go 类型
+	Comment string
+	Text    bool // 需要本地化的文本, 开启 WithLocalize 时提取到字符串表
+}
+
+// Table 一个配置文件的解析结果
+type Table struct {
+	Layout Layout
+	Fields []Field // 记录结构
+	Elem   string  // 元素不是结构时的 go 类型, 非空时忽略 Fields
+
+	// RowsLayout 为 map[int]*json.Object, ObjectLayout 为 *json.Object, ArrayLayout 为 *json.Array
+	Data interface{}
+}
+
+// SourceParser 将一种格式的配置文件解析为结构与数据
+type SourceParser interface {
+	Parse(path string) (*Table, error)
+}
+
+var (
+	parsers   = make(map[string]SourceParser)
+	parsersMu sync.RWMutex
+)
+
+// RegisterParser 按扩展名注册解析器, 同名扩展名会被覆盖
+func RegisterParser(ext string, parser SourceParser) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+	parsers[normalizeExt(ext)] = parser
+}
+
+// GetParser 获取扩展名对应的解析器
+func GetParser(ext string) (SourceParser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	parser, ok := parsers[normalizeExt(ext)]
+	return parser, ok
+}
+
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+func catchParse(path string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("parse %s: %v", path, r)
+	}
+}
+
+type csvParser struct{}
+
+func (csvParser) Parse(path string) (table *Table, err error) {
+	defer catchParse(path, &err)
+
+	reader, err := csv.ReadCsv(path)
+	if err != nil {
+		return nil, err
+	}
+	table = &Table{
+		Layout: RowsLayout,
+		Fields: make([]Field, 0, len(reader.Keys)),
+	}
+	for j, key := range reader.Keys {
+		typ := reader.KeyTypes[j]
+		if j == 0 {
+			key = "ID"
+			typ = csv.IntType
+		}
+		table.Fields = append(table.Fields, Field{
+			Name:    key,
+			Type:    csv.GoTypes[typ],
+			Comment: reader.Comments[j],
+			Text:    reader.Texts[j],
+		})
+	}
+
+	rows := make(map[int]*json.Object, len(reader.Content))
+	for _, values := range reader.Content {
+		record := json.NewObject()
+		for idx, value := range values {
+			if idx == 0 {
+				id, _ := strconv.Atoi(value)
+				record.Set("ID", id)
+			} else {
+				conVal := csv.ConvKeyType(value, reader.KeyTypes[idx])
+				if conVal == nil {
+					conVal = csv.ConvKeyType(reader.Defs[idx], reader.KeyTypes[idx])
+				}
+				record.Set(reader.Keys[idx], conVal)
+			}
+		}
+		id, _ := record.GetInt("ID")
+		rows[id] = record
+	}
+	table.Data = rows
+	return table, nil
+}
+
+type jsonParser struct{}
+
+func (jsonParser) Parse(path string) (table *Table, err error) {
+	defer catchParse(path, &err)
+
+	table = &Table{}
+	var obj *json.Object
+	var texts []string
+	if json.ValidJSONFile(path) == json.ArrayType {
+		array := json.LoadJSONArray(path)
+		if texts, err = json.TextKeys(array); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+		if err = json.ConvTimes(array); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+		table.Layout = ArrayLayout
+		table.Data = array
+		eleTyp, _ := json.CheckArray(array)
+		if eleTyp != json.MapType {
+			table.Elem = json.GoTypes[eleTyp]
+			return table, nil
+		}
+		obj, _ = array.GetObject(0)
+	} else {
+		obj = json.LoadJSONObject(path)
+		if texts, err = json.TextKeys(obj); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+		if err = json.ConvTimes(obj); err != nil {
+			return nil, fmt.Errorf("parse %s: %v", path, err)
+		}
+		table.Layout = ObjectLayout
+		table.Data = obj
+	}
+
+	for _, gen := range json.ParseJSONObject(obj) {
+		table.Fields = append(table.Fields, Field{
+			Name:    gen.Name,
+			Type:    gen.Type,
+			Comment: gen.Comment,
+			Text:    coll_utils.In(gen.Name, texts),
+		})
+	}
+	return table, nil
+}
+
+func init() {
+	RegisterParser(".csv", csvParser{})
+	RegisterParser(".json", jsonParser{})
+}
@@ -0,0 +1,248 @@
+package validate
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Error 带表与行上下文的校验错误
+type Error struct {
+	Table string      // 表路径, 整体校验时为空
+	Row   interface{} // 行键, 整表或整体校验时为 nil
+	Err   error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Table == "":
+		return e.Err.Error()
+	case e.Row == nil:
+		return fmt.Sprintf("%s: %v", e.Table, e.Err)
+	default:
+		return fmt.Sprintf("%s[%v]: %v", e.Table, e.Row, e.Err)
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors 聚合的校验错误
+type Errors []*Error
+
+func (es Errors) Error() string {
+	lines := make([]string, 0, len(es))
+	for _, e := range es {
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Err 没有错误时返回 nil
+func (es Errors) Err() error {
+	if len(es) == 0 {
+		return nil
+	}
+	return es
+}
+
+type RowFunc func(key, row interface{}) error
+
+type TableFunc func(table interface{}) error
+
+type ConfigFunc func(cfg interface{}) error
+
+type table struct {
+	name   string
+	fields []string
+	rows   []RowFunc
+	tables []TableFunc
+}
+
+// Registry 校验注册表, 由生成的配置代码声明表, 业务代码注册校验函数
+type Registry struct {
+	mu      sync.RWMutex
+	tables  []*table
+	index   map[string]*table
+	configs []ConfigFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		index: make(map[string]*table),
+	}
+}
+
+// Table 声明表, fields 为从配置根到表字段的字段名
+func (r *Registry) Table(name string, fields ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.index[name]; ok {
+		t.fields = fields
+		return
+	}
+	t := &table{name: name, fields: fields}
+	r.tables = append(r.tables, t)
+	r.index[name] = t
+}
+
+// OnRow 注册行校验
+func (r *Registry) OnRow(name string, f RowFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.index[name]
+	if !ok {
+		panic(fmt.Sprintf("validate: table %s not declared", name))
+	}
+	t.rows = append(t.rows, f)
+}
+
+// OnTable 注册整表校验
+func (r *Registry) OnTable(name string, f TableFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.index[name]
+	if !ok {
+		panic(fmt.Sprintf("validate: table %s not declared", name))
+	}
+	t.tables = append(t.tables, f)
+}
+
+// OnConfig 注册整体校验
+func (r *Registry) OnConfig(f ConfigFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs = append(r.configs, f)
+}
+
+// Run 执行全部校验, 返回聚合后的错误
+func (r *Registry) Run(cfg interface{}) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs Errors
+	root := reflect.ValueOf(cfg)
+	for _, t := range r.tables {
+		if len(t.rows) == 0 && len(t.tables) == 0 {
+			continue
+		}
+		value, ok := lookup(root, t.fields)
+		if !ok {
+			continue
+		}
+		for _, f := range t.tables {
+			if err := call(func() error { return f(value.Interface()) }); err != nil {
+				errs = append(errs, &Error{Table: t.name, Err: err})
+			}
+		}
+		if len(t.rows) == 0 {
+			continue
+		}
+		eachRow(value, func(key, row interface{}) {
+			for _, f := range t.rows {
+				if err := call(func() error { return f(key, row) }); err != nil {
+					errs = append(errs, &Error{Table: t.name, Row: key, Err: err})
+				}
+			}
+		})
+	}
+	for _, f := range r.configs {
+		if err := call(func() error { return f(cfg) }); err != nil {
+			errs = append(errs, &Error{Err: err})
+		}
+	}
+	return errs.Err()
+}
+
+func call(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f()
+}
+
+func lookup(v reflect.Value, fields []string) (reflect.Value, bool) {
+	for _, field := range fields {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return v, false
+		}
+		v = v.FieldByName(field)
+		if !v.IsValid() {
+			return v, false
+		}
+	}
+	return v, true
+}
+
+// eachRow 按键序遍历表中的行, 值为切片时展开为 key[idx]
+func eachRow(table reflect.Value, f func(key, row interface{})) {
+	if table.Kind() != reflect.Map {
+		return
+	}
+	keys := table.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessKey(keys[i], keys[j])
+	})
+	for _, key := range keys {
+		value := table.MapIndex(key)
+		if value.Kind() == reflect.Slice {
+			for idx := 0; idx < value.Len(); idx++ {
+				f(fmt.Sprintf("%v[%d]", key.Interface(), idx), value.Index(idx).Interface())
+			}
+			continue
+		}
+		f(key.Interface(), value.Interface())
+	}
+}
+
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.String:
+		return a.String() < b.String()
+	default:
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+}
+
+// Main 校验命令入口, 依次校验参数中的配置文件, 有错误时以非零状态退出
+//
+//	func main() {
+//		validate.Main(conf.ValidateBlob)
+//	}
+func Main(check func(data []byte) error) {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <config> ...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, path := range os.Args[1:] {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = check(data)
+		}
+		if err != nil {
+			failed = true
+			fmt.Fprintf(os.Stderr, "%s:\n%v\n", path, err)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,198 @@
+package times
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+	clock := times.NewFakeClock(times.Date(2024, 1, 1, 4, 59, 0, 0))
+	times.SetClock(clock)
+	wheel := algorithm.NewTimeWheel(10*time.Millisecond, 100, algorithm.WithClock(clock))
+	clock.Advance(time.Minute) // 05:00 的定时器到期
+
+Clock 统一获取当前时间与等待, 默认使用系统时间. FakeClock 只在调用 Advance/Set 时前进, 用于测试与 GM 调时间.
+*/
+
+// Clock 时钟
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// Ticker 周期触发, 与 time.Ticker 一样在接收不及时时丢弃触发
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// clock 当前时钟, 存放 clockHolder 以便替换为不同类型的实现
+var clock atomic.Value
+
+type clockHolder struct {
+	Clock
+}
+
+func init() {
+	clock.Store(clockHolder{RealClock})
+}
+
+// SetClock 设置 Now 等函数使用的时钟, 可以与 Now 并发调用. nil 恢复为系统时钟
+func SetClock(c Clock) {
+	if c == nil {
+		c = RealClock
+	}
+	clock.Store(clockHolder{c})
+}
+
+func GetClock() Clock {
+	return clock.Load().(clockHolder).Clock
+}
+
+// RealClock 系统时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// FakeClock 手动推进的时钟, 可以在任意协程中使用
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // 大于 0 时为 Ticker
+	c      chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, &fakeWaiter{at: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Sleep 阻塞到时钟被推进 d 之后
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return &fakeTicker{clock: c, waiter: w}
+}
+
+// Advance 向前推进 d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	now := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(now)
+}
+
+// Set 设置当前时间, 到期的 After/Sleep 按到期时间的顺序触发, Ticker 只触发一次并带上新的当前时间.
+// 时间回拨时不会触发任何等待
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].at.Before(c.waiters[j].at)
+	})
+	remain := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(now) {
+			remain = append(remain, w)
+			continue
+		}
+		if w.period <= 0 {
+			w.c <- w.at
+			continue
+		}
+		select {
+		case w.c <- now:
+		default:
+		}
+		w.at = w.at.Add((now.Sub(w.at)/w.period + 1) * w.period)
+		remain = append(remain, w)
+	}
+	c.waiters = remain
+}
+
+// Waiters 尚未触发的 After/Sleep 与 Ticker 的数量, 用于等待其他协程进入等待
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	waiter *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.waiter.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, w := range t.clock.waiters {
+		if w == t.waiter {
+			t.clock.waiters = append(t.clock.waiters[:i], t.clock.waiters[i+1:]...)
+			return
+		}
+	}
+}
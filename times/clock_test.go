@@ -0,0 +1,155 @@
+package times
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var fakeStart = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func recv(t *testing.T, c <-chan time.Time) time.Time {
+	t.Helper()
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(time.Second):
+		t.Fatal("channel did not fire")
+	}
+	return time.Time{}
+}
+
+func TestFakeClockAfter(t *testing.T) {
+	clock := NewFakeClock(fakeStart)
+	late := clock.After(3 * time.Second)
+	early := clock.After(time.Second)
+	if v := recv(t, clock.After(0)); !v.Equal(fakeStart) {
+		t.Fatalf("After(0) = %v", v)
+	}
+	if clock.Waiters() != 2 {
+		t.Fatalf("Waiters = %d, want 2", clock.Waiters())
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if len(early) != 0 || len(late) != 0 {
+		t.Fatal("After fired early")
+	}
+	// 一次推进越过多个等待, 按到期时间触发并带上各自的到期时间
+	clock.Advance(5 * time.Second)
+	if v := recv(t, early); !v.Equal(fakeStart.Add(time.Second)) {
+		t.Fatalf("early fired with %v", v)
+	}
+	if v := recv(t, late); !v.Equal(fakeStart.Add(3 * time.Second)) {
+		t.Fatalf("late fired with %v", v)
+	}
+	if clock.Waiters() != 0 {
+		t.Fatalf("Waiters = %d after firing", clock.Waiters())
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clock := NewFakeClock(fakeStart)
+	var wg sync.WaitGroup
+	woke := make(chan time.Duration, 3)
+	for _, d := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second} {
+		d := d
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clock.Sleep(d)
+			woke <- d
+		}()
+	}
+	for clock.Waiters() < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if d := <-woke; d != time.Second {
+		t.Fatalf("woke %v, want 1s", d)
+	}
+	// 回拨不会唤醒
+	clock.Set(fakeStart)
+	select {
+	case d := <-woke:
+		t.Fatalf("woke %v after setting the clock back", d)
+	case <-time.After(10 * time.Millisecond):
+	}
+	clock.Advance(3 * time.Second)
+	wg.Wait()
+	if len(woke) != 2 {
+		t.Fatalf("%d sleepers woke, want 2", len(woke))
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clock := NewFakeClock(fakeStart)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	if len(ticker.C()) != 0 {
+		t.Fatal("ticker fired early")
+	}
+	// 越过多个周期只触发一次, 带上新的当前时间
+	clock.Advance(2500 * time.Millisecond)
+	if v := recv(t, ticker.C()); !v.Equal(fakeStart.Add(3499 * time.Millisecond)) {
+		t.Fatalf("ticker fired with %v", v)
+	}
+	if len(ticker.C()) != 0 {
+		t.Fatal("ticker fired more than once for one Advance")
+	}
+	// 下一次在 4s
+	clock.Advance(500 * time.Millisecond)
+	if len(ticker.C()) != 0 {
+		t.Fatal("ticker fired before the next period")
+	}
+	clock.Advance(time.Millisecond)
+	recv(t, ticker.C())
+
+	// 未接收时丢弃触发
+	clock.Advance(time.Second)
+	clock.Advance(time.Second)
+	if v := recv(t, ticker.C()); !v.Equal(fakeStart.Add(5 * time.Second)) {
+		t.Fatalf("buffered tick = %v, want the first undelivered one", v)
+	}
+
+	ticker.Stop()
+	if clock.Waiters() != 0 {
+		t.Fatal("Stop did not remove the ticker")
+	}
+	clock.Advance(time.Hour)
+	if len(ticker.C()) != 0 {
+		t.Fatal("stopped ticker fired")
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	clock := NewFakeClock(fakeStart)
+	SetClock(clock)
+	defer SetClock(nil)
+	if !Now().Equal(fakeStart) {
+		t.Fatalf("Now = %v, want %v", Now(), fakeStart)
+	}
+	clock.Advance(time.Hour)
+	if !Now().Equal(fakeStart.Add(time.Hour)) {
+		t.Fatalf("Now did not follow the fake clock")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			Now()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetClock(clock)
+	}
+	wg.Wait()
+
+	SetClock(nil)
+	if GetClock() != RealClock {
+		t.Fatal("SetClock(nil) did not restore RealClock")
+	}
+}
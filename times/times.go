@@ -1,6 +1,11 @@
 package times
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 var location *time.Location
 
@@ -17,6 +22,11 @@ func SetLocation(loc *time.Location) {
 	location = loc
 }
 
+// Location 当前使用的时区
+func Location() *time.Location {
+	return location
+}
+
 func InitTimeZone(language string) {
 	switch language {
 	case "cn":
@@ -35,7 +45,7 @@ func InitTimeZone(language string) {
 const OneDay = 24 * time.Hour
 
 func Now() time.Time {
-	return time.Now().In(location)
+	return GetClock().Now().In(location)
 }
 
 func Date(year, month, day, hour, min, sec, nsec int) time.Time {
@@ -72,3 +82,84 @@ func DateTimeCombine(date int, hour, min, sec int) time.Time {
 func init() {
 	location = UTC8Zone
 }
+
+// 配置中支持的时间格式, 按 location 解析
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"20060102150405",
+	"20060102",
+	time.RFC3339,
+}
+
+// ParseTime 解析时间字符串, 支持 2006-01-02 15:04:05、2006-01-02、20060102 等格式
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// ParseDuration 解析时长字符串, 在 time.ParseDuration 基础上支持天(1d12h), 纯数字按秒处理
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+
+	var days time.Duration
+	if idx := strings.Index(s, "d"); idx > 0 {
+		n, err := strconv.ParseInt(s[:idx], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		days = time.Duration(n) * OneDay
+		s = s[idx+1:]
+		if s == "" {
+			return days, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return days + d, nil
+}
+
+// IsTimeString 是否为时间字符串, 纯数字不视为时间
+func IsTimeString(s string) bool {
+	if isDigits(s) {
+		return false
+	}
+	_, err := ParseTime(s)
+	return err == nil
+}
+
+// IsDurationString 是否为时长字符串, 纯数字不视为时长
+func IsDurationString(s string) bool {
+	if isDigits(s) {
+		return false
+	}
+	_, err := ParseDuration(s)
+	return err == nil
+}
+
+func isDigits(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}